@@ -0,0 +1,595 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	lg "github.com/charmbracelet/lipgloss"
+)
+
+// Browser is the full-screen `cmgr browse` interface: a collapsible tree of
+// every node next to a preview of the selected one. All operations act on
+// the same in-memory tree as the one-shot commands.
+
+type BrowserMode int
+
+const (
+	BROWSER_NAVIGATE BrowserMode = iota
+	BROWSER_INPUT
+	BROWSER_CONFIRM
+)
+
+const browser_help = "↑↓ move  ←→ fold  n new  N sibling  r rename  m move  p put  d remove  c current  o open  b build  q quit"
+
+type BrowserRow struct {
+	Node  *Node
+	Depth int
+}
+
+type Browser struct {
+	expanded map[*Node]bool
+	current  map[*Node]bool
+	rows     []BrowserRow
+	cursor   int
+	offset   int
+	width    int
+	height   int
+
+	mode    BrowserMode
+	input   textinput.Model
+	prompt  string
+	submit  func(value string) error
+	confirm func() error
+
+	moving *Node
+	status string
+}
+
+type BuildDoneMsg struct {
+	Result *BuildResult
+	Err    error
+}
+
+type EditorDoneMsg struct {
+	Err error
+}
+
+func run_browser() error {
+
+	// Hooks and warnings must not write over the screen; their output is
+	// shown in the status line instead.
+	CapturedOutput = &bytes.Buffer{}
+	defer func() { CapturedOutput = nil }()
+
+	_, err := tea.NewProgram(new_browser(), tea.WithAltScreen()).Run()
+	return err
+}
+
+func new_browser() *Browser {
+
+	b := &Browser{
+		expanded: map[*Node]bool{},
+		input:    textinput.New(),
+		width:    80,
+		height:   24,
+	}
+
+	b.input.Prompt = ""
+
+	b.refresh()
+
+	for depth := len(CFG_DEPTH_GROUP) - 1; depth >= 0; depth-- {
+		if node := get_current_node(CFG_DEPTH_GROUP[depth]); node != nil {
+			b.select_node(node)
+			break
+		}
+	}
+
+	return b
+}
+
+func (b *Browser) get_roots() []*Node {
+	var roots []*Node
+	for _, node := range Nodes {
+		if node.get_depth() == 0 {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+// refresh rebuilds the visible rows after the tree or the currents changed.
+func (b *Browser) refresh() {
+
+	b.current = get_current_chain()
+	b.rows = nil
+
+	var add func(node *Node, depth int)
+	add = func(node *Node, depth int) {
+		b.rows = append(b.rows, BrowserRow{node, depth})
+		if b.expanded[node] {
+			for _, child := range node.get_children() {
+				add(child, depth+1)
+			}
+		}
+	}
+
+	for _, root := range b.get_roots() {
+		add(root, 0)
+	}
+
+	b.cursor = max(min(b.cursor, len(b.rows)-1), 0)
+}
+
+func (b *Browser) selected() *Node {
+	if b.cursor >= 0 && b.cursor < len(b.rows) {
+		return b.rows[b.cursor].Node
+	}
+	return nil
+}
+
+// select_node expands the ancestors of node and moves the cursor onto it.
+func (b *Browser) select_node(node *Node) {
+	for parent := node.get_parent(); parent != nil; parent = parent.get_parent() {
+		b.expanded[parent] = true
+	}
+
+	b.refresh()
+
+	for i, row := range b.rows {
+		if row.Node == node {
+			b.cursor = i
+		}
+	}
+}
+
+func (b *Browser) Init() tea.Cmd {
+	return nil
+}
+
+func (b *Browser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+
+	switch msg := msg.(type) {
+
+	case tea.WindowSizeMsg:
+		b.width, b.height = msg.Width, msg.Height
+		return b, nil
+
+	case BuildDoneMsg:
+		if msg.Err != nil {
+			b.status = msg.Err.Error()
+		} else {
+			finish_build(msg.Result)
+			b.status = msg.Result.String()
+		}
+		b.show_output()
+		clear(node_stats)
+		return b, nil
+
+	case EditorDoneMsg:
+		if msg.Err != nil {
+			b.status = msg.Err.Error()
+		}
+		clear(node_stats)
+		return b, nil
+
+	case tea.KeyMsg:
+		switch b.mode {
+		case BROWSER_INPUT:
+			return b.update_input(msg)
+		case BROWSER_CONFIRM:
+			return b.update_confirm(msg)
+		}
+		return b.update_navigate(msg)
+	}
+
+	return b, nil
+}
+
+func (b *Browser) update_navigate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+
+	node := b.selected()
+
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return b, tea.Quit
+
+	case "up", "k":
+		b.cursor = max(b.cursor-1, 0)
+
+	case "down", "j":
+		b.cursor = max(min(b.cursor+1, len(b.rows)-1), 0)
+
+	case "g", "home":
+		b.cursor = 0
+
+	case "G", "end":
+		b.cursor = max(len(b.rows)-1, 0)
+
+	case "left", "h":
+		if node == nil {
+			break
+		}
+		if b.expanded[node] && len(node.get_children()) > 0 {
+			b.expanded[node] = false
+			b.refresh()
+		} else if node.get_parent() != nil {
+			b.select_node(node.get_parent())
+		}
+
+	case "right", "l":
+		if node == nil || len(node.get_children()) < 1 {
+			break
+		}
+		if b.expanded[node] {
+			b.cursor++
+		} else {
+			b.expanded[node] = true
+			b.refresh()
+		}
+
+	case "enter", " ":
+		if node != nil {
+			b.expanded[node] = !b.expanded[node]
+			b.refresh()
+		}
+
+	case "esc":
+		b.moving = nil
+		b.status = ""
+
+	case "n":
+		if node == nil {
+			b.start_create(nil, CFG_DEPTH_GROUP[0])
+		} else if node.get_depth() < len(CFG_DEPTH_GROUP)-1 {
+			b.start_create(node, CFG_DEPTH_GROUP[node.get_depth()+1])
+		} else {
+			b.start_create(node.get_parent(), node.get_group())
+		}
+
+	case "N":
+		if node != nil {
+			b.start_create(node.get_parent(), node.get_group())
+		}
+
+	case "r":
+		if node != nil {
+			b.start_rename(node)
+		}
+
+	case "m":
+		if node != nil && node.get_depth() > 0 {
+			b.moving = node
+			b.status = fmt.Sprintf("Moving %v '%v': select a %v and press p (esc cancels)",
+				node.get_group(), node.get_title(), CFG_DEPTH_GROUP[node.get_depth()-1])
+		}
+
+	case "p":
+		if b.moving != nil && node != nil {
+			b.run(func() error { return b.move(b.moving, node) })
+		}
+
+	case "d":
+		if node != nil {
+			b.start_remove(node)
+		}
+
+	case "c":
+		if node != nil {
+			b.run(func() error { return set_currents_to(node) })
+		}
+
+	case "o", "e":
+		if node != nil {
+			return b, b.open(node)
+		}
+
+	case "b":
+		if node == nil {
+			break
+		}
+
+		// Only the compiler runs in the background; hooks and the recorded
+		// result stay on the update loop with every other write.
+		result, err := prepare_build(node)
+		if err != nil {
+			b.status = err.Error()
+			b.show_output()
+			break
+		}
+
+		b.status = fmt.Sprintf("Building %v...", result.Node.get_title())
+		b.show_output()
+
+		return b, func() tea.Msg {
+			return BuildDoneMsg{result, compile_build(result)}
+		}
+	}
+
+	return b, nil
+}
+
+func (b *Browser) update_input(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		b.mode = BROWSER_NAVIGATE
+		b.status = ""
+		return b, nil
+
+	case "enter":
+		b.mode = BROWSER_NAVIGATE
+		value := b.input.Value()
+		b.run(func() error { return b.submit(value) })
+		return b, nil
+	}
+
+	var cmd tea.Cmd
+	b.input, cmd = b.input.Update(msg)
+
+	return b, cmd
+}
+
+func (b *Browser) update_confirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+
+	b.mode = BROWSER_NAVIGATE
+
+	if msg.String() == "y" || msg.String() == "Y" {
+		b.run(b.confirm)
+	} else {
+		b.status = "Cancelled."
+	}
+
+	return b, nil
+}
+
+// run performs an operation and refreshes the tree, reporting any error in
+// the status line.
+func (b *Browser) run(operation func() error) {
+
+	if err := operation(); err != nil {
+		b.status = err.Error()
+		Changed = nil
+	}
+
+	if index, err := update_index(); err == nil {
+		NoteIndex = index
+	}
+
+	b.show_output()
+
+	clear(node_stats)
+	b.refresh()
+}
+
+// show_output appends the last line of any captured output to the status
+// line, e.g. a failing hook's message.
+func (b *Browser) show_output() {
+
+	if CapturedOutput == nil {
+		return
+	}
+
+	output := strings.TrimSpace(CapturedOutput.String())
+	CapturedOutput.Reset()
+
+	if output == "" {
+		return
+	}
+
+	lines := strings.Split(output, "\n")
+	b.status = strings.TrimSpace(b.status + " " + strings.TrimSpace(lines[len(lines)-1]))
+}
+
+func (b *Browser) start_input(prompt, value string, submit func(value string) error) {
+	b.mode = BROWSER_INPUT
+	b.prompt = prompt
+	b.submit = submit
+	b.input.SetValue(value)
+	b.input.CursorEnd()
+	b.input.Focus()
+}
+
+func (b *Browser) start_create(parent *Node, group string) {
+	b.start_input(fmt.Sprintf("New %v: ", group), "", func(title string) error {
+
+		title = strings.TrimSpace(title)
+		if title == "" {
+			return fmt.Errorf("a title is required")
+		}
+
+		if parent != nil {
+			for _, child := range parent.get_children() {
+				if child.get_title() == title {
+					return fmt.Errorf("%v already exists: '%v'", group, title)
+				}
+			}
+			if err := set_currents_to(parent); err != nil {
+				return err
+			}
+		}
+
+		node, err := create_node(group, title, "", "")
+		if err != nil {
+			return err
+		}
+
+		if parent != nil {
+			if err := add_children_to_input_file(parent); err != nil {
+				return err
+			}
+		}
+
+		b.select_node(node)
+		b.status = fmt.Sprintf("Created %v '%v'.", group, title)
+
+		return commit_changes(fmt.Sprintf("Add %v '%v' to %v", group, title, get_commit_location(node)))
+	})
+}
+
+func (b *Browser) start_rename(node *Node) {
+	b.start_input(fmt.Sprintf("Rename %v: ", node.get_group()), node.get_title(), func(title string) error {
+
+		old_title := node.get_title()
+
+		if err := rename_node(node, title); err != nil {
+			return err
+		}
+
+		b.status = fmt.Sprintf("Renamed '%v' to '%v'.", old_title, node.get_title())
+
+		return commit_changes(fmt.Sprintf("Rename %v '%v' to '%v' in %v",
+			node.get_group(), old_title, node.get_title(), get_commit_location(node)))
+	})
+}
+
+func (b *Browser) start_remove(node *Node) {
+
+	b.mode = BROWSER_CONFIRM
+	b.prompt = fmt.Sprintf("Remove %v '%v' and everything below it? [y/N]", node.get_group(), node.get_title())
+
+	if warning := get_removal_warning(node); warning != "" {
+		b.prompt = strings.ReplaceAll(warning, "\n", "; ") + "\n" + b.prompt
+	}
+
+	b.confirm = func() error {
+		if err := remove_node(node); err != nil {
+			return err
+		}
+
+		b.status = fmt.Sprintf("Removed %v '%v'.", node.get_group(), node.get_title())
+
+		return commit_changes(fmt.Sprintf("Remove %v '%v' from %v", node.get_group(), node.get_title(), get_commit_location(node)))
+	}
+}
+
+func (b *Browser) move(node, parent *Node) error {
+
+	if err := move_node(node, parent); err != nil {
+		return err
+	}
+
+	b.moving = nil
+	b.select_node(node)
+	b.status = fmt.Sprintf("Moved %v '%v' to %v.", node.get_group(), node.get_title(), parent.get_breadcrumb())
+
+	return commit_changes(fmt.Sprintf("Move %v '%v' to %v", node.get_group(), node.get_title(), get_commit_location(node)))
+}
+
+// open suspends the browser while the node is edited.
+func (b *Browser) open(node *Node) tea.Cmd {
+
+	path, err := node.get_open_path()
+	if err == nil && path == "" {
+		err = fmt.Errorf("'%v' has no file to open", node.get_title())
+	}
+	if err != nil {
+		b.status = err.Error()
+		return nil
+	}
+
+	name, arguments, err := editor_command(path, 0)
+	if err != nil {
+		b.status = err.Error()
+		return nil
+	}
+
+	return tea.ExecProcess(exec.Command(name, arguments...), func(err error) tea.Msg {
+		return EditorDoneMsg{err}
+	})
+}
+
+// get_node_preview returns the start of a lecture's source or a directory
+// node's info.json.
+func get_node_preview(node *Node, lines int) string {
+
+	path := node.get_path()
+	if filepath.Ext(path) != CFG_NOTE_FILETYPE {
+		path = filepath.Join(path, CFG_INFO_FILENAME+".json")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err.Error()
+	}
+
+	content := strings.Split(strings.ReplaceAll(string(data), "\t", "    "), "\n")
+	if len(content) > lines {
+		content = content[:lines]
+	}
+
+	return strings.Join(content, "\n")
+}
+
+func (b *Browser) View() string {
+
+	pane_height := max(b.height-4, 1)
+	tree_width := max(b.width*2/5-2, 10)
+	preview_width := max(b.width-tree_width-4, 10)
+
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	} else if b.cursor >= b.offset+pane_height {
+		b.offset = b.cursor - pane_height + 1
+	}
+
+	var rows []string
+
+	for i := b.offset; i < len(b.rows) && i < b.offset+pane_height; i++ {
+		row := b.rows[i]
+
+		marker := "  "
+		if len(row.Node.get_children()) > 0 {
+			marker = "▸ "
+			if b.expanded[row.Node] {
+				marker = "▾ "
+			}
+		}
+
+		label := strings.Repeat("  ", row.Depth) + marker + row.Node.get_title()
+		if row.Node == b.moving {
+			label += " (moving)"
+		}
+
+		switch {
+		case i == b.cursor:
+			label = selected_style.Render(label)
+		case b.current[row.Node]:
+			label = bold_style.Render(label)
+		}
+
+		rows = append(rows, label)
+	}
+
+	clip := func(width int) lg.Style {
+		return lg.NewStyle().MaxWidth(width).MaxHeight(pane_height)
+	}
+
+	tree_pane := tree_style.Width(tree_width).Height(pane_height).
+		Render(clip(tree_width - 2).Render(strings.Join(rows, "\n")))
+
+	preview := ""
+	if node := b.selected(); node != nil {
+		preview = bold_style.Render(node.get_breadcrumb()) + "\n" + get_node_preview(node, pane_height-1)
+	}
+
+	preview_pane := tree_style.Width(preview_width).Height(pane_height).
+		Render(clip(preview_width - 2).Render(preview))
+
+	footer := b.status + "\n" + browser_help
+
+	switch b.mode {
+	case BROWSER_INPUT:
+		footer = b.prompt + b.input.View() + "\n" + "enter confirm  esc cancel"
+	case BROWSER_CONFIRM:
+		footer = b.prompt
+	}
+
+	return lg.JoinVertical(lg.Left, lg.JoinHorizontal(lg.Top, tree_pane, preview_pane), footer)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type BuildResult struct {
+	Node     *Node
+	File     string
+	Errors   int
+	Duration time.Duration
+}
+
+var log_error_pattern = regexp.MustCompile(`^(!|.+:\d+: )`)
+
+// get_build_target walks up from node and returns the closest ancestor whose
+// composite file can be compiled on its own, i.e. one containing \documentclass.
+func get_build_target(node *Node) (*Node, string, error) {
+	for current := node; current != nil; current = current.get_parent() {
+		if filepath.Ext(current.get_path()) == CFG_NOTE_FILETYPE {
+			continue
+		}
+
+		composite_file, err := get_composite_file(current.get_path())
+		if err != nil || composite_file == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(composite_file)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if strings.Contains(string(data), `\documentclass`) {
+			return current, composite_file, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("no buildable document found for '%v'", node.get_title())
+}
+
+// build_node builds the document node belongs to and records the result.
+func build_node(node *Node) (*BuildResult, error) {
+
+	result, err := prepare_build(node)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := compile_build(result); err != nil {
+		return nil, err
+	}
+
+	finish_build(result)
+
+	return result, nil
+}
+
+// prepare_build finds the document to build for node and runs the pre-build
+// hooks.
+func prepare_build(node *Node) (*BuildResult, error) {
+
+	target, composite_file, err := get_build_target(node)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := run_pre_hooks(HOOK_BUILD, target, nil); err != nil {
+		return nil, err
+	}
+
+	return &BuildResult{Node: target, File: composite_file}, nil
+}
+
+// compile_build runs the build command for result and counts the errors in
+// its log. It changes no files besides the build output, so it may run in
+// the background.
+func compile_build(result *BuildResult) error {
+
+	composite_file := result.File
+
+	output_dir := filepath.Join(filepath.Dir(composite_file), CFG_BUILD_DIR)
+
+	arguments := append([]string{}, CFG_BUILD_ARGUMENTS...)
+	arguments = append(arguments, "-outdir="+output_dir, filepath.Base(composite_file))
+
+	var output bytes.Buffer
+
+	cmd := exec.Command(CFG_BUILD_COMMAND, arguments...)
+	cmd.Dir = filepath.Dir(composite_file)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	run_err := cmd.Run()
+
+	result.Duration = time.Since(start)
+
+	log_file := filepath.Join(output_dir, strings.TrimSuffix(filepath.Base(composite_file), CFG_NOTE_FILETYPE)+".log")
+
+	errors, err := count_log_errors(log_file)
+	if err != nil {
+		if run_err != nil {
+			return fmt.Errorf("failed to build %s: %w\n%s", composite_file, run_err, output.String())
+		}
+		return err
+	}
+
+	if run_err != nil && errors == 0 {
+		errors = 1
+	}
+
+	result.Errors = errors
+
+	return nil
+}
+
+// finish_build records result and runs the post-build hooks.
+func finish_build(result *BuildResult) {
+
+	// The build itself succeeded, so a failure to store its result is only
+	// reported.
+	if err := record_build_result(result); err != nil {
+		warn(fmt.Errorf("unable to record build result: %w", err))
+	}
+
+	run_post_hooks(HOOK_BUILD, result.Node, map[string]string{"BUILD_ERRORS": strconv.Itoa(result.Errors)})
+}
+
+func count_log_errors(path string) (int, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := 0
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if log_error_pattern.MatchString(scanner.Text()) {
+			count++
+		}
+	}
+
+	return count, scanner.Err()
+}
+
+func record_build_result(result *BuildResult) error {
+	info_path := filepath.Join(result.Node.get_path(), CFG_INFO_FILENAME+".json")
+
+	if err := write_json_value(info_path, "last-build", time.Now().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return write_json_value(info_path, "last-build-errors", strconv.Itoa(result.Errors))
+}
+
+func (r *BuildResult) String() string {
+	status := "ok"
+	if r.Errors == 1 {
+		status = "1 error"
+	} else if r.Errors > 1 {
+		status = fmt.Sprintf("%v errors", r.Errors)
+	}
+
+	return fmt.Sprintf("[%v] %v: %v (%.1fs)",
+		time.Now().Format("15:04:05"),
+		r.Node.get_title(),
+		status,
+		r.Duration.Seconds())
+}
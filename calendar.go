@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// get_node_date returns a lecture's date, or for other nodes the date of
+// their earliest lecture. Dates sort chronologically as strings.
+func get_node_date(node *Node) string {
+	if node.get_depth() == len(CFG_DEPTH_GROUP)-1 {
+		return node.get_date()
+	}
+
+	earliest := ""
+	for _, child := range node.get_children() {
+		if date := get_node_date(child); date != "" && (earliest == "" || date < earliest) {
+			earliest = date
+		}
+	}
+	return earliest
+}
+
+// get_dated_lectures returns the lectures below node in chronological order,
+// followed by the lectures without a valid date.
+func get_dated_lectures(node *Node) ([]*Node, []*Node) {
+
+	var dated, undated []*Node
+
+	walk_nodes(node, func(n *Node) {
+		if n.get_depth() != len(CFG_DEPTH_GROUP)-1 {
+			return
+		}
+		if _, err := time.Parse(CFG_DATE_FORMAT, n.get_date()); err == nil {
+			dated = append(dated, n)
+		} else {
+			undated = append(undated, n)
+		}
+	})
+
+	sort.SliceStable(dated, func(i, j int) bool {
+		return dated[i].get_date() < dated[j].get_date()
+	})
+
+	return dated, undated
+}
+
+// get_week_start returns the Monday of date's week.
+func get_week_start(date time.Time) time.Time {
+	return date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+}
+
+func print_calendar(node *Node) {
+
+	dated, undated := get_dated_lectures(node)
+
+	fmt.Println(bold_style.Render(node.get_breadcrumb()))
+
+	var week time.Time
+
+	for _, lecture := range dated {
+		date, _ := time.Parse(CFG_DATE_FORMAT, lecture.get_date())
+
+		if start := get_week_start(date); !start.Equal(week) {
+			week = start
+			_, number := week.ISOWeek()
+			fmt.Printf("\nWeek %v (%v – %v)\n", number,
+				week.Format(CFG_DATE_FORMAT), week.AddDate(0, 0, 6).Format(CFG_DATE_FORMAT))
+		}
+
+		fmt.Printf("  %v %v  %v\n", date.Format("Mon"), lecture.get_date(), get_calendar_label(node, lecture))
+	}
+
+	if len(undated) > 0 {
+		fmt.Printf("\nUndated (%v)\n", len(undated))
+		for _, lecture := range undated {
+			fmt.Printf("  %v\n", get_calendar_label(node, lecture))
+		}
+	}
+}
+
+// get_calendar_label describes lecture relative to the calendar's root.
+func get_calendar_label(root, lecture *Node) string {
+	label := lecture.get_title()
+	for current := lecture.get_parent(); current != nil && current != root; current = current.get_parent() {
+		label = current.get_title() + CFG_BREADCRUMB_SEPARATOR + label
+	}
+	return label
+}
@@ -1,26 +1,108 @@
 package main
 
+import "time"
+
 const (
-	CFG_CONFIG_DIR          = "/Users/max/.config/course-manager/config.json"
-	CFG_SEMESTER_DIR        = "data/semester"
-	CFG_TEMPLATE_DIR        = "data/templates"
-	CFG_CURRENT_NODE_PREFIX = "current-"
-	CFG_ROOT_FIELD          = "root-dir"
-	CFG_INFO_FILENAME       = "info"
-	CFG_REPLACE_MARKER      = "%%"
-	CFG_NOTE_FILETYPE       = ".tex"
-	CFG_EDITOR              = "vim"
+	CFG_CONFIG_DIR            = "/Users/max/.config/course-manager/config.json"
+	CFG_SEMESTER_DIR          = "data/semester"
+	CFG_TEMPLATE_DIR          = "data/templates"
+	CFG_OVERRIDE_TEMPLATE_DIR = ".cmgr/templates"
+	CFG_CURRENT_NODE_PREFIX   = "current-"
+	CFG_ROOT_FIELD            = "root-dir"
+	CFG_INFO_FILENAME         = "info"
+	CFG_REPLACE_MARKER        = "%%"
+	CFG_VARIABLES_FIELD       = "variables"
+	CFG_DATE_FORMAT_FIELD     = "date-format"
+	CFG_TIME_FORMAT_FIELD     = "time-format"
+	CFG_DATE_FORMAT           = "2006-01-02"
+	CFG_TIME_FORMAT           = "15:04"
+	CFG_TEMPLATE_LEFT_DELIM   = "((*"
+	CFG_TEMPLATE_RIGHT_DELIM  = "*))"
+	CFG_TEMPLATE_META_PREFIX  = "$"
+	CFG_VERSION_KEY           = "$version"
+	CFG_POST_CREATE_KEY       = "$post_create"
+	CFG_STRUCTURE_VERSION     = 2
+	CFG_SUBSTITUTE_KEY        = "$substitute"
+	CFG_COMPOSITE_ORDER_FIELD = "composite-order"
+	CFG_ORDER_CHRONOLOGICAL   = "chronological"
+	CFG_SCHEDULE_FIELD        = "schedule"
+	CFG_SCHEDULE_TITLE        = "Lecture %%number%%"
+	CFG_MEETING_DURATION      = 90
+	CFG_ASSIGNMENTS_FIELD     = "assignments"
+	CFG_DEADLINES_FIELD       = "deadlines"
+	CFG_SCHEDULE_PREVIEW      = 10
+	CFG_META_ID               = "ID"
+	CFG_META_DATE             = "DATE"
+	CFG_META_TAGS             = "TAGS"
+	CFG_NOTE_FILETYPE         = ".tex"
+	CFG_EDITOR                = "vim -c :VimtexCompile +%%line%% %%file%%"
+	CFG_BREADCRUMB_SEPARATOR  = " › "
+	CFG_GIT_FIELD             = "git"
+	CFG_HOOKS_FIELD           = "hooks"
+	CFG_EDITOR_FIELD          = "editor"
+	CFG_EDITOR_TYPES_FIELD    = "editor-filetypes"
+	CFG_BUILD_COMMAND         = "latexmk"
+	CFG_BUILD_DIR             = "build"
+	CFG_INDEX_FILE            = ".cmgr/index.json"
+	CFG_MAX_LINE_LENGTH       = 1 << 20
+	CFG_PREVIEW_LINES         = 20
+	CFG_PICKER_HEIGHT         = 12
+	CFG_WATCH_DEBOUNCE        = 500 * time.Millisecond
 )
 
-var CFG_NOTE_ARGUMENTS = []string{
-	"-c",
-	":VimtexCompile",
+// CFG_CONFIG_VARIABLES are config fields exposed to templates as %%field%%.
+var CFG_CONFIG_VARIABLES = []string{
+	"author",
+	"institution",
+}
+
+var CFG_BUILD_ARGUMENTS = []string{
+	"-pdf",
+	"-interaction=nonstopmode",
+	"-file-line-error",
+}
+
+var CFG_WATCH_FILETYPES = []string{
+	".tex",
+	".bib",
+	".sty",
+	".pdf_tex",
+}
+
+var CFG_INDEX_ENVIRONMENTS = []string{
+	"definition",
+	"theorem",
+	"lemma",
+	"prop",
+	"corollary",
+	"eg",
+	"prb",
+	"remark",
+	"note",
+	"notation",
+	"property",
 }
 
 var CFG_ALIASES = [][]string{
 	{"new", "n"},
 	{"current", "cur"},
 	{"tree", "t"},
+	{"open", "o"},
+	{"pick", "p", "fzf"},
+	{"grep", "g"},
+	{"find", "f"},
+	{"refs", "ref"},
+	{"lint"},
+	{"template", "tpl"},
+	{"log"},
+	{"status"},
+	{"browse", "ui"},
+	{"calendar", "cal"},
+	{"schedule"},
+	{"export"},
+	{"todo"},
+	{"build", "b"},
+	{"watch", "w"},
 	{"remove", "rem", "rm"},
 	{"semester", "sem"},
 	{"course", "cou", "co"},
@@ -55,6 +137,10 @@ func get_config_value(field string) (string, error) {
 	return result, nil
 }
 
+func get_config_field(field string) (interface{}, error) {
+	return read_json_field(CFG_CONFIG_DIR, field)
+}
+
 func set_config_value(field, value string) error {
 	err := write_json_value(CFG_CONFIG_DIR, field, value)
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -8,44 +9,79 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 )
 
-// create_file_structure takes a template structure, where keys are either
-// directories (map[string]interface{}) or source-file paths (string),
-// and recursively creates the corresponding directory tree and copies files.
-func create_file_structure(template map[string]interface{}, root string) error {
-	for name, value := range template {
-		currentPath := filepath.Join(root, name)
-
-		switch v := value.(type) {
-		case string:
-			// Before copying, check that we don't overwrite an existing file
-			if _, err := os.Stat(currentPath); err == nil {
-				return fmt.Errorf("file %s already exists", currentPath)
-			} else if !errors.Is(err, os.ErrNotExist) {
-				// If we get some other error, return it
-				return fmt.Errorf("error checking existence of %s: %w", currentPath, err)
+// create_file_structure recursively creates the directories, files and
+// symlinks described by the structure entries below root, skipping entries
+// whose when condition does not hold and optional entries whose source is
+// missing. It returns the paths of everything it created.
+func create_file_structure(entries []*StructureEntry, root string, variables map[string]string) ([]string, error) {
+	var created []string
+
+	for _, entry := range entries {
+		if !evaluate_condition(entry.When, variables) {
+			continue
+		}
+
+		currentPath := filepath.Join(root, entry.Name)
+
+		// Before creating anything, check that we don't overwrite an existing file
+		if _, err := os.Lstat(currentPath); err == nil && entry.Type != STRUCTURE_DIR {
+			return nil, fmt.Errorf("file %s already exists", currentPath)
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			// If we get some other error, return it
+			return nil, fmt.Errorf("error checking existence of %s: %w", currentPath, err)
+		}
+
+		switch entry.Type {
+		case STRUCTURE_FILE:
+			if entry.Content != nil {
+				if err := os.WriteFile(currentPath, []byte(*entry.Content), 0644); err != nil {
+					return nil, fmt.Errorf("failed to write %s: %w", currentPath, err)
+				}
+			} else {
+				if _, err := os.Stat(entry.Source); entry.Optional && errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				if err := copy_file(entry.Source, currentPath); err != nil {
+					return nil, err
+				}
 			}
 
-			if err := copy_file(v, currentPath); err != nil {
-				return err
+		case STRUCTURE_SYMLINK:
+			if err := os.Symlink(entry.Symlink, currentPath); err != nil {
+				if entry.Optional {
+					continue
+				}
+				return nil, fmt.Errorf("failed to link %s: %w", currentPath, err)
 			}
 
-		case map[string]interface{}:
+		case STRUCTURE_DIR:
 			// Create the subdirectory if it doesn't exist
 			if err := os.MkdirAll(currentPath, os.ModePerm); err != nil {
-				return fmt.Errorf("failed to create directory %s: %w", currentPath, err)
+				return nil, fmt.Errorf("failed to create directory %s: %w", currentPath, err)
 			}
 			// Recursively create the structure within that subdirectory
-			if err := create_file_structure(v, currentPath); err != nil {
-				return err
+			nested, err := create_file_structure(entry.Children, currentPath, variables)
+			if err != nil {
+				return nil, err
 			}
 
-		default:
-			return fmt.Errorf("unexpected value type for key %s", name)
+			created = append(created, nested...)
+		}
+
+		if entry.Mode != 0 && entry.Type != STRUCTURE_SYMLINK {
+			if err := os.Chmod(currentPath, entry.Mode); err != nil {
+				return nil, err
+			}
+		}
+
+		if entry.Type != STRUCTURE_DIR {
+			created = append(created, currentPath)
 		}
 	}
-	return nil
+	return created, nil
 }
 
 // copy_file copies the contents of src to dst. If dst exists, an error is returned.
@@ -75,6 +111,31 @@ func copy_file(src, dst string) error {
 	return nil
 }
 
+// is_binary_file reports whether the start of the file contains NUL bytes or
+// invalid UTF-8, in which case placeholders are never substituted.
+func is_binary_file(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	buffer := make([]byte, 8000)
+	n, err := file.Read(buffer)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+
+	valid := utf8.Valid(buffer[:n])
+
+	// Don't mistake a rune cut in half by the buffer boundary for binary data.
+	for i := 1; !valid && n == len(buffer) && i < utf8.UTFMax; i++ {
+		valid = utf8.Valid(buffer[:n-i])
+	}
+
+	return bytes.IndexByte(buffer[:n], 0) >= 0 || !valid, nil
+}
+
 // find_path searches a directory tree for a file or directory named 'title'.
 // mode can be "f"/"file" or "d"/"dir"/"directory".
 func find_path(mode, root, title string) (string, error) {
@@ -119,19 +180,161 @@ func find_path(mode, root, title string) (string, error) {
 	return "", fmt.Errorf("directory or file '%s' not found in '%s'", title, root)
 }
 
+// find_file_by_name returns the first file below root whose name is exactly
+// name, searching breadth-first like find_path.
+func find_file_by_name(root, name string) (string, error) {
+	queue := []string{root}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		entries, err := os.ReadDir(current)
+		if err != nil {
+			return "", err
+		}
+
+		for _, entry := range entries {
+			entryPath := filepath.Join(current, entry.Name())
+
+			if entry.IsDir() {
+				queue = append(queue, entryPath)
+			} else if entry.Name() == name {
+				return entryPath, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("file '%s' not found in '%s'", name, root)
+}
+
 // open_note spawns an external editor to open a note (Node).
 func open_note(node *Node) error {
-	CFG_NOTE_ARGUMENTS = append(CFG_NOTE_ARGUMENTS, node.get_path())
+	path, err := node.get_open_path()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("nothing to open for %v '%v'", node.get_group(), node.get_title())
+	}
+	return open_file(path, 0)
+}
 
-	cmd := exec.Command(CFG_EDITOR, CFG_NOTE_ARGUMENTS...)
+// open_file spawns the configured editor on path, jumping to line if the
+// editor template supports it. A line of 0 opens the file at the top.
+func open_file(path string, line int) error {
+	name, arguments, err := editor_command(path, line)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(name, arguments...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
-		return fmt.Errorf("Failed to open tex file: %s", err.Error())
+		return fmt.Errorf("Failed to open %s: %s", path, err.Error())
 	}
 
 	return nil
 }
+
+// editor_template returns the command template used to open path. Per-filetype
+// overrides in the config take precedence over the configured editor, which in
+// turn takes precedence over $VISUAL, $EDITOR and CFG_EDITOR.
+func editor_template(path string) string {
+	if overrides, err := get_config_field(CFG_EDITOR_TYPES_FIELD); err == nil {
+		if overrides, ok := overrides.(map[string]interface{}); ok {
+			if template, ok := overrides[filepath.Ext(path)].(string); ok && template != "" {
+				return template
+			}
+		}
+	}
+
+	if template, err := get_config_value(CFG_EDITOR_FIELD); err == nil && template != "" {
+		return template
+	}
+
+	for _, variable := range []string{"VISUAL", "EDITOR"} {
+		if template := os.Getenv(variable); template != "" {
+			return template
+		}
+	}
+
+	return CFG_EDITOR
+}
+
+// editor_command builds a fresh argument list from the editor template,
+// substituting the %%file%% and %%line%% placeholders. Templates without a
+// file placeholder get the path appended.
+func editor_command(path string, line int) (string, []string, error) {
+	if line < 1 {
+		line = 1
+	}
+
+	file_marker := CFG_REPLACE_MARKER + "file" + CFG_REPLACE_MARKER
+	line_marker := CFG_REPLACE_MARKER + "line" + CFG_REPLACE_MARKER
+
+	words := split_command(editor_template(path))
+	if len(words) < 1 {
+		return "", nil, fmt.Errorf("no editor configured")
+	}
+
+	has_file := false
+
+	var arguments []string
+
+	for _, word := range words[1:] {
+		if strings.Contains(word, file_marker) {
+			has_file = true
+		}
+		word = strings.ReplaceAll(word, file_marker, path)
+		word = strings.ReplaceAll(word, line_marker, fmt.Sprint(line))
+		arguments = append(arguments, word)
+	}
+
+	if !has_file {
+		arguments = append(arguments, path)
+	}
+
+	return words[0], arguments, nil
+}
+
+// split_command splits a command template into words, keeping quoted
+// sections together.
+func split_command(command string) []string {
+	var words []string
+	var word strings.Builder
+
+	quote := rune(0)
+	in_word := false
+
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			in_word = true
+		case r == ' ' || r == '\t':
+			if in_word {
+				words = append(words, word.String())
+				word.Reset()
+				in_word = false
+			}
+		default:
+			word.WriteRune(r)
+			in_word = true
+		}
+	}
+
+	if in_word {
+		words = append(words, word.String())
+	}
+
+	return words
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss/tree"
@@ -48,12 +47,39 @@ import (
 
  */
 
-func node_creation_form(group string) (*Node, error) {
+func node_creation_form(group, template_name, date string) (*Node, error) {
 
 	if !valid_node_group(group) {
 		return nil, fmt.Errorf("invalid node group '%v'", group)
 	}
 
+	var parent *Node
+
+	if CFG_GROUP_DEPTH[group] > 0 {
+		parent = get_current_node(CFG_DEPTH_GROUP[CFG_GROUP_DEPTH[group]-1])
+	}
+
+	templates, err := list_templates(parent, group)
+	if err != nil {
+		return nil, err
+	}
+
+	preset_template := template_name != ""
+
+	if preset_template {
+		if _, err := find_named_template(parent, group, template_name); err != nil {
+			return nil, err
+		}
+	} else {
+		template_name = templates[0].Name
+	}
+
+	var template_names []string
+
+	for _, template := range templates {
+		template_names = append(template_names, template.Name)
+	}
+
 	num_group_members := 0
 
 	for _, node := range Nodes {
@@ -142,6 +168,25 @@ func node_creation_form(group string) (*Node, error) {
 					return tree_style.Render(t.String())
 				}, &choices),
 		),
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Choose a template").
+				Options(huh.NewOptions(template_names...)...).
+				Value(&template_name),
+
+			huh.NewNote().
+				Title("Template").
+				DescriptionFunc(func() string {
+					for _, template := range templates {
+						if template.Name == template_name {
+							return get_template_preview(template.Path)
+						}
+					}
+					return ""
+				}, &template_name),
+		).WithHideFunc(func() bool {
+			return preset_template || len(templates) < 2
+		}),
 		huh.NewGroup(
 			huh.NewConfirm().
 				Title(fmt.Sprintf("Create new %v ''?", group)).
@@ -154,14 +199,14 @@ func node_creation_form(group string) (*Node, error) {
 		WithLayout(huh.LayoutStack).
 		WithProgramOptions(tea.WithAltScreen())
 
-	err := form.Run()
+	err = form.Run()
 
 	if err != nil {
 		return nil, err
 	}
 
 	if confirm {
-		node, err := create_node(group, choices[0])
+		node, err := create_node(group, choices[0], template_name, date)
 		if err != nil {
 			return nil, err
 		}
@@ -170,6 +215,11 @@ func node_creation_form(group string) (*Node, error) {
 			add_children_to_input_file(node.get_parent())
 		}
 
+		message := fmt.Sprintf("Add %v '%v' to %v", group, node.get_title(), get_commit_location(node))
+		if err := commit_changes(message); err != nil {
+			return node, err
+		}
+
 		return node, nil
 	}
 
@@ -266,7 +316,23 @@ func set_currents_form(group string) error {
 		return err
 	}
 
-	err = set_config_value(CFG_CURRENT_NODE_PREFIX+group, choices[0])
+	var chosen *Node
+
+	for _, node := range group_nodes_objects {
+		if node.get_title() == choices[0] {
+			chosen = node
+		}
+	}
+
+	if err := run_pre_hooks(HOOK_SET_CURRENT, chosen, nil); err != nil {
+		return err
+	}
+
+	if err := set_config_value(CFG_CURRENT_NODE_PREFIX+group, choices[0]); err != nil {
+		return err
+	}
+
+	run_post_hooks(HOOK_SET_CURRENT, chosen, nil)
 
 	if group_depth < len(CFG_GROUP_DEPTH)-1 {
 		set_currents_form(CFG_DEPTH_GROUP[group_depth+1])
@@ -313,6 +379,26 @@ func node_deletion_form(group string) error {
 
 	var confirmed [2]bool
 
+	selected := func() *Node {
+		for _, node := range Nodes {
+			if node.get_group() != group || node.get_title() != choices[0] {
+				continue
+			}
+			if CFG_GROUP_DEPTH[group] > 0 && node.get_parent().get_title() != current_parent_title {
+				continue
+			}
+			return node
+		}
+		return nil
+	}
+
+	removal_description := func() string {
+		if warning := get_removal_warning(selected()); warning != "" {
+			return warning + "\n\nThis action cannot be undone."
+		}
+		return "This action cannot be undone."
+	}
+
 	form := huh.NewForm(
 
 		huh.NewGroup(
@@ -370,7 +456,7 @@ func node_deletion_form(group string) error {
 			huh.NewConfirm().TitleFunc(func() string {
 				return fmt.Sprintf("Remove '%v'?", choices[0])
 			}, &choices).Value(&confirmed[0]).
-				Description("This action cannot be undone."),
+				DescriptionFunc(removal_description, &choices),
 		),
 
 		huh.NewGroup(
@@ -399,16 +485,14 @@ func node_deletion_form(group string) error {
 	}
 
 	if confirmed[0] && confirmed[1] {
-		for _, node := range Nodes {
-			if node.get_group() == group && node.get_title() == choices[0] {
-				err := remove_from_parent_input_file(node)
-				if err != nil {
-					return err
-				}
-
-				os.RemoveAll(node.get_path())
+		if node := selected(); node != nil {
+			if err := remove_node(node); err != nil {
+				return err
+			}
 
-				break
+			message := fmt.Sprintf("Remove %v '%v' from %v", group, node.get_title(), get_commit_location(node))
+			if err := commit_changes(message); err != nil {
+				return err
 			}
 		}
 
@@ -417,3 +501,202 @@ func node_deletion_form(group string) error {
 
 	return nil
 }
+
+// node_picker_form lets the user type a fuzzy query over the breadcrumbs of
+// all nodes and pick one of the ranked matches.
+func node_picker_form(title string) (*Node, error) {
+
+	if len(Nodes) < 1 {
+		return nil, fmt.Errorf("no nodes to select")
+	}
+
+	var query string
+	var choice *Node
+
+	form := huh.NewForm(
+		huh.NewGroup(node_picker_fields(title, &query, &choice)...),
+	).WithTheme(form_theme).
+		WithLayout(huh.LayoutStack).
+		WithProgramOptions(tea.WithAltScreen())
+
+	if err := form.Run(); err != nil {
+		return nil, err
+	}
+
+	if choice == nil {
+		return nil, fmt.Errorf("no node selected")
+	}
+
+	return choice, nil
+}
+
+func node_picker_fields(title string, query *string, choice **Node) []huh.Field {
+	return []huh.Field{
+		huh.NewInput().
+			Title(title).
+			Placeholder("type to search").
+			Value(query),
+
+		huh.NewSelect[*Node]().
+			OptionsFunc(func() []huh.Option[*Node] {
+				var options []huh.Option[*Node]
+				for _, node := range fuzzy_rank(*query, Nodes) {
+					options = append(options, huh.NewOption(node.get_breadcrumb(), node))
+				}
+				return options
+			}, query).
+			Height(CFG_PICKER_HEIGHT).
+			Value(choice),
+	}
+}
+
+func fuzzy_finder_form() error {
+
+	if len(Nodes) < 1 {
+		return fmt.Errorf("no nodes to select")
+	}
+
+	var query, action string
+	var choice *Node
+	var confirmed bool
+
+	fields := node_picker_fields("Search nodes", &query, &choice)
+	fields = append(fields,
+		huh.NewSelect[string]().
+			Title("Action").
+			Options(huh.NewOptions("open", "set current", "remove")...).
+			Value(&action),
+	)
+
+	form := huh.NewForm(
+
+		huh.NewGroup(fields...),
+
+		huh.NewGroup(
+			huh.NewConfirm().
+				TitleFunc(func() string {
+					if choice == nil {
+						return ""
+					}
+					return fmt.Sprintf("Remove '%v'?", choice.get_breadcrumb())
+				}, &choice).
+				DescriptionFunc(func() string {
+					if warning := get_removal_warning(choice); warning != "" {
+						return warning + "\n\nThis action cannot be undone."
+					}
+					return "This action cannot be undone."
+				}, &choice).
+				Value(&confirmed),
+		).WithHideFunc(func() bool {
+			return action != "remove"
+		}),
+	).WithTheme(form_theme).
+		WithLayout(huh.LayoutStack).
+		WithProgramOptions(tea.WithAltScreen())
+
+	if err := form.Run(); err != nil {
+		return err
+	}
+
+	if choice == nil {
+		return fmt.Errorf("no node selected")
+	}
+
+	switch action {
+	case "open":
+		return open_note(choice)
+
+	case "set current":
+		if err := set_currents_to(choice); err != nil {
+			return err
+		}
+		fmt.Printf("Current %v: '%v'.\n", choice.get_group(), choice.get_breadcrumb())
+
+	case "remove":
+		if !confirmed {
+			return nil
+		}
+		if err := remove_node(choice); err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("Remove %v '%v' from %v", choice.get_group(), choice.get_title(), get_commit_location(choice))
+		if err := commit_changes(message); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %v '%v'.\n", choice.get_group(), choice.get_title())
+	}
+
+	return nil
+}
+
+func todo_picker_form(items []TodoItem) (*TodoItem, error) {
+
+	if len(items) < 1 {
+		return nil, fmt.Errorf("no assignments")
+	}
+
+	var options []huh.Option[int]
+
+	for i, item := range items {
+		options = append(options, huh.NewOption(item.String(), i))
+	}
+
+	var choice int
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[int]().
+				Title("Choose an assignment").
+				Options(options...).
+				Height(CFG_PICKER_HEIGHT).
+				Value(&choice),
+		),
+	).WithTheme(form_theme).
+		WithLayout(huh.LayoutStack).
+		WithProgramOptions(tea.WithAltScreen())
+
+	if err := form.Run(); err != nil {
+		return nil, err
+	}
+
+	return &items[choice], nil
+}
+
+func search_hit_form(hits []SearchHit) (*SearchHit, error) {
+
+	if len(hits) < 1 {
+		return nil, fmt.Errorf("no matches")
+	}
+
+	var options []huh.Option[int]
+
+	for i, hit := range hits {
+		label := hit.String()
+		if hit.Node != nil {
+			label = fmt.Sprintf("%v%v%v", hit.Node.get_breadcrumb(), CFG_BREADCRUMB_SEPARATOR, hit)
+		}
+		options = append(options, huh.NewOption(label, i))
+	}
+
+	var choice int
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[int]().
+				Title("Choose a match to open").
+				Options(options...).
+				Filtering(true).
+				Height(CFG_PICKER_HEIGHT).
+				Value(&choice),
+		),
+	).WithTheme(form_theme).
+		WithLayout(huh.LayoutStack).
+		WithProgramOptions(tea.WithAltScreen())
+
+	if err := form.Run(); err != nil {
+		return nil, err
+	}
+
+	return &hits[choice], nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// fuzzy_score matches query as a subsequence of candidate, ignoring case and
+// whitespace in the query. Consecutive matches and matches at the start of a
+// word score higher, so "anlim" prefers "Analysis › Limits" over "Algebra".
+func fuzzy_score(query, candidate string) (int, bool) {
+	needle := []rune(strings.ToLower(strings.Join(strings.Fields(query), "")))
+	haystack := []rune(strings.ToLower(candidate))
+
+	score := 0
+	previous := -2
+	matched := 0
+
+	for i := 0; i < len(haystack) && matched < len(needle); i++ {
+		if haystack[i] != needle[matched] {
+			continue
+		}
+
+		score++
+
+		if i == previous+1 {
+			score += 5
+		}
+
+		if i == 0 || !unicode.IsLetter(haystack[i-1]) && !unicode.IsDigit(haystack[i-1]) {
+			score += 3
+		}
+
+		previous = i
+		matched++
+	}
+
+	if matched < len(needle) {
+		return 0, false
+	}
+
+	return score*100 - len(haystack), true
+}
+
+// fuzzy_rank returns the nodes whose breadcrumb matches query, best first.
+func fuzzy_rank(query string, nodes []*Node) []*Node {
+
+	type ranked struct {
+		node  *Node
+		score int
+	}
+
+	var matches []ranked
+
+	for _, node := range nodes {
+		if score, ok := fuzzy_score(query, node.get_breadcrumb()); ok {
+			matches = append(matches, ranked{node, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	result := make([]*Node, 0, len(matches))
+	for _, match := range matches {
+		result = append(result, match.node)
+	}
+
+	return result
+}
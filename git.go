@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Changed collects every path cmgr created, modified or removed during this
+// run, so that git mode can commit exactly those files.
+var Changed []string
+
+func track_change(paths ...string) {
+	Changed = append(Changed, paths...)
+}
+
+func git_enabled() bool {
+	value, err := get_config_field(CFG_GIT_FIELD)
+	if err != nil {
+		return false
+	}
+	return value == true || value == "true"
+}
+
+func run_git(dir string, arguments ...string) (string, error) {
+	var output bytes.Buffer
+
+	cmd := exec.Command("git", arguments...)
+	cmd.Dir = dir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if err != nil {
+		return output.String(), fmt.Errorf("git %v: %w\n%s", arguments[0], err, output.String())
+	}
+
+	return strings.TrimSpace(output.String()), nil
+}
+
+func get_git_root() (string, error) {
+	root_dir, err := get_config_value(CFG_ROOT_FIELD)
+	if err != nil {
+		return "", err
+	}
+	return run_git(root_dir, "rev-parse", "--show-toplevel")
+}
+
+// commit_changes stages and commits the tracked changes with message if git
+// mode is enabled. Other staged or modified files are left alone.
+func commit_changes(message string) error {
+
+	defer func() { Changed = nil }()
+
+	if !git_enabled() || len(Changed) < 1 {
+		return nil
+	}
+
+	repo, err := get_git_root()
+	if err != nil {
+		return err
+	}
+
+	var pathspecs []string
+	seen := map[string]bool{}
+
+	for _, path := range Changed {
+		rel, err := filepath.Rel(repo, path)
+		if err != nil || strings.HasPrefix(rel, "..") || seen[rel] {
+			continue
+		}
+		seen[rel] = true
+
+		if _, err := os.Lstat(path); err == nil {
+			if _, err := run_git(repo, "add", "-A", "--", rel); err != nil {
+				return err
+			}
+			pathspecs = append(pathspecs, rel)
+		} else if _, err := run_git(repo, "cat-file", "-e", "HEAD:"+filepath.ToSlash(rel)); err == nil {
+			// Removed paths only need committing if git knew about them.
+			pathspecs = append(pathspecs, rel)
+		}
+	}
+
+	if len(pathspecs) < 1 {
+		return nil
+	}
+
+	arguments := append([]string{"commit", "-q", "-m", message, "--"}, pathspecs...)
+	_, err = run_git(repo, arguments...)
+
+	return err
+}
+
+func get_commit_location(node *Node) string {
+	var titles []string
+	for ancestor := node.get_parent(); ancestor != nil && ancestor.get_depth() > 0; ancestor = ancestor.get_parent() {
+		titles = append([]string{ancestor.get_title()}, titles...)
+	}
+	if len(titles) < 1 {
+		return "root"
+	}
+	return strings.Join(titles, "/")
+}
+
+func print_node_log(node *Node) error {
+
+	repo, err := get_git_root()
+	if err != nil {
+		return err
+	}
+
+	arguments := []string{"log", "--date=short", "--format=%h %ad %an  %s"}
+	if filepath.Ext(node.get_path()) == CFG_NOTE_FILETYPE {
+		arguments = append(arguments, "--follow")
+	}
+	arguments = append(arguments, "--", node.get_path())
+
+	cmd := exec.Command("git", arguments...)
+	cmd.Dir = repo
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type SearchHit struct {
+	Node *Node
+	Path string
+	Line int
+	Text string
+}
+
+// search_notes scans every note file below root for pattern, ignoring LaTeX
+// comments, and returns the hits in file order.
+func search_notes(root string, pattern *regexp.Regexp) ([]SearchHit, error) {
+
+	var hits []SearchHit
+
+	err := walk_note_files(root, func(path string) error {
+		// One unreadable file should not hide the matches in all others.
+		file, err := os.Open(path)
+		if err != nil {
+			warn(err)
+			return nil
+		}
+		defer file.Close()
+
+		node := find_node_by_path(path)
+
+		scanner := new_line_scanner(file)
+		for line := 1; scanner.Scan(); line++ {
+			text := strip_tex_comment(scanner.Text())
+			if !pattern.MatchString(text) {
+				continue
+			}
+			hits = append(hits, SearchHit{
+				Node: node,
+				Path: path,
+				Line: line,
+				Text: strings.TrimSpace(text),
+			})
+		}
+
+		if err := scanner.Err(); err != nil {
+			warn(fmt.Errorf("skipping %v: %w", path, err))
+		}
+
+		return nil
+	})
+
+	return hits, err
+}
+
+func (h SearchHit) String() string {
+	return fmt.Sprintf("%v:%v: %v", filepath.Base(h.Path), h.Line, h.Text)
+}
+
+func print_search_hits(hits []SearchHit) {
+
+	var previous *Node
+
+	for i, hit := range hits {
+		if i == 0 || hit.Node != previous {
+			if i > 0 {
+				fmt.Println()
+			}
+			if hit.Node != nil {
+				fmt.Println(bold_style.Render(hit.Node.get_breadcrumb()))
+			} else {
+				fmt.Println(bold_style.Render(filepath.Dir(hit.Path)))
+			}
+			previous = hit.Node
+		}
+
+		fmt.Printf("  %v\n", hit)
+	}
+}
+
+// get_search_root returns the directory a search is scoped to: the current
+// node of group if one is given, otherwise the whole semester directory.
+func get_search_root(group string) (string, error) {
+	if group != "" {
+		node := get_current_node(group)
+		if node == nil {
+			return "", fmt.Errorf("unable to find current %v", group)
+		}
+		return node.get_path(), nil
+	}
+
+	root_dir, err := get_config_value(CFG_ROOT_FIELD)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(root_dir, CFG_SEMESTER_DIR), nil
+}
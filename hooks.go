@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Hooks are shell commands configured per stage and operation, e.g.
+//
+//	"hooks": {
+//	  "post-create": ["git add -A \"$CMGR_NODE_PATH\""],
+//	  "pre-remove": "test \"$CMGR_GROUP\" != course"
+//	}
+//
+// A failing pre-hook aborts the operation; a failing post-hook only warns.
+// Hooks share the terminal unless CapturedOutput is set.
+
+const (
+	HOOK_PRE  = "pre"
+	HOOK_POST = "post"
+
+	HOOK_CREATE      = "create"
+	HOOK_REMOVE      = "remove"
+	HOOK_RENAME      = "rename"
+	HOOK_MOVE        = "move"
+	HOOK_SET_CURRENT = "set-current"
+	HOOK_BUILD       = "build"
+)
+
+func get_hook_commands(stage, operation string) []string {
+	hooks, err := get_config_field(CFG_HOOKS_FIELD)
+	if err != nil {
+		return nil
+	}
+
+	if hooks, ok := hooks.(map[string]interface{}); ok {
+		if commands, err := parse_commands(hooks[stage+"-"+operation]); err == nil {
+			return commands
+		}
+	}
+
+	return nil
+}
+
+// get_hook_environment describes node to hook and post_create commands
+// through CMGR_* environment variables.
+func get_hook_environment(node *Node, extra map[string]string) []string {
+
+	environment := os.Environ()
+
+	add := func(name, value string) {
+		environment = append(environment, "CMGR_"+name+"="+value)
+	}
+
+	if node != nil {
+		add("NODE_ID", node.get_id())
+		add("NODE_PATH", node.get_path())
+		add("NODE_TITLE", node.get_title())
+		add("GROUP", node.get_group())
+		add("BREADCRUMB", node.get_breadcrumb())
+
+		if parent := node.get_parent(); parent != nil {
+			add("PARENT_ID", parent.get_id())
+			add("PARENT_PATH", parent.get_path())
+		}
+
+		for ancestor := node; ancestor != nil; ancestor = ancestor.get_parent() {
+			add(strings.ToUpper(ancestor.get_group()), ancestor.get_title())
+		}
+	}
+
+	for name, value := range extra {
+		add(name, value)
+	}
+
+	return environment
+}
+
+func run_commands(commands []string, dir string, environment []string) error {
+	for _, command := range commands {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Dir = dir
+		cmd.Env = environment
+
+		// Captured commands get no input, as nothing could answer it.
+		if CapturedOutput != nil {
+			cmd.Stdout = CapturedOutput
+			cmd.Stderr = CapturedOutput
+		} else {
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+		}
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("command '%v' failed: %w", command, err)
+		}
+	}
+	return nil
+}
+
+// run_hooks runs the configured hooks for stage and operation in the root
+// directory.
+func run_hooks(stage, operation string, node *Node, extra map[string]string) error {
+
+	commands := get_hook_commands(stage, operation)
+	if len(commands) < 1 {
+		return nil
+	}
+
+	root_dir, err := get_config_value(CFG_ROOT_FIELD)
+	if err != nil {
+		return err
+	}
+
+	if extra == nil {
+		extra = map[string]string{}
+	}
+	extra["OPERATION"] = operation
+	extra["STAGE"] = stage
+
+	if err := run_commands(commands, root_dir, get_hook_environment(node, extra)); err != nil {
+		return fmt.Errorf("%v-%v hook: %w", stage, operation, err)
+	}
+
+	return nil
+}
+
+// run_pre_hooks runs the pre hooks of operation, whose failure aborts it.
+func run_pre_hooks(operation string, node *Node, extra map[string]string) error {
+	return run_hooks(HOOK_PRE, operation, node, extra)
+}
+
+// run_post_hooks runs the post hooks of operation. The operation already
+// happened, so failures are only reported.
+func run_post_hooks(operation string, node *Node, extra map[string]string) {
+	if err := run_hooks(HOOK_POST, operation, node, extra); err != nil {
+		warn(err)
+	}
+}
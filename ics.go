@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Deadlines are stored in a course's info.json, e.g.
+//
+//	"deadlines": [{"title": "Midterm", "due": "2024-10-15 09:00"}]
+//
+// A due date without a time is an all-day event. Assignments (see todo.go)
+// are exported the same way. An optional "id" keeps the event's UID stable
+// when the title or due date is edited.
+
+type Deadline struct {
+	ID          string `json:"id,omitempty"`
+	Title       string `json:"title"`
+	Due         string `json:"due"`
+	Description string `json:"description"`
+}
+
+type CalendarEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	AllDay      bool
+	Duration    time.Duration
+}
+
+const (
+	ICS_DATE_FORMAT     = "20060102"
+	ICS_DATETIME_FORMAT = "20060102T150405"
+	ICS_LINE_LENGTH     = 75
+)
+
+func get_course_deadlines(course *Node) ([]Deadline, error) {
+	var deadlines []Deadline
+	err := decode_course_field(course, CFG_DEADLINES_FIELD, &deadlines)
+	return deadlines, err
+}
+
+// parse_due parses a due date, with or without a time of day.
+func parse_due(due string) (time.Time, bool, error) {
+	if date, err := time.ParseInLocation(CFG_DATE_FORMAT+" "+CFG_TIME_FORMAT, due, time.Local); err == nil {
+		return date, false, nil
+	}
+	if date, err := time.ParseInLocation(CFG_DATE_FORMAT, due, time.Local); err == nil {
+		return date, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid due date '%v' (expected %v [%v])", due, CFG_DATE_FORMAT, CFG_TIME_FORMAT)
+}
+
+// get_event_uid derives a UID from the course id and a name that is stable
+// within the course.
+func get_event_uid(course *Node, name string) string {
+	key := course.get_id()
+	if key == "" {
+		key = course.get_path()
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("cmgr:"+key+"/"+name)).String() + "@cmgr"
+}
+
+// get_lecture_uid uses the id stored in the lecture's note, so the event
+// survives renames and moves. Notes created before ids were stored fall
+// back to their path within the course.
+func get_lecture_uid(course, lecture *Node) string {
+	if metadata, err := read_note_metadata(lecture.get_path()); err == nil && metadata[CFG_META_ID] != "" {
+		return metadata[CFG_META_ID] + "@cmgr"
+	}
+	rel, _ := filepath.Rel(course.get_path(), lecture.get_path())
+	return get_event_uid(course, "lecture/"+filepath.ToSlash(rel))
+}
+
+// get_item_uid returns the UID of a deadline or assignment, derived from its
+// id or, lacking one, from its title and due date.
+func get_item_uid(course *Node, kind, id, title, due string) string {
+	if id != "" {
+		return get_event_uid(course, kind+"/"+id)
+	}
+	return get_event_uid(course, kind+"/"+title+"/"+due)
+}
+
+func get_course_events(course *Node) ([]CalendarEvent, error) {
+
+	var events []CalendarEvent
+
+	slots := map[*Node]ScheduleSlot{}
+	if schedule, err := get_course_schedule(course); err == nil {
+		scheduled, err := get_schedule_slots(schedule, course)
+		if err != nil {
+			return nil, err
+		}
+		for _, slot := range scheduled {
+			if slot.Lecture != nil {
+				slots[slot.Lecture] = slot
+			}
+		}
+	}
+
+	dated, _ := get_dated_lectures(course)
+
+	for _, lecture := range dated {
+		event := CalendarEvent{
+			UID:         get_lecture_uid(course, lecture),
+			Summary:     course.get_title() + ": " + lecture.get_title(),
+			Description: lecture.get_breadcrumb(),
+		}
+
+		if slot, ok := slots[lecture]; ok {
+			event.Start, event.Duration = slot.Time, slot.Duration
+		} else {
+			event.Start, _ = time.ParseInLocation(CFG_DATE_FORMAT, lecture.get_date(), time.Local)
+			event.AllDay = true
+		}
+
+		events = append(events, event)
+	}
+
+	deadlines, err := get_course_deadlines(course)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, deadline := range deadlines {
+		due, all_day, err := parse_due(deadline.Due)
+		if err != nil {
+			return nil, fmt.Errorf("%v deadline '%v': %w", course.get_title(), deadline.Title, err)
+		}
+
+		events = append(events, CalendarEvent{
+			UID:         get_item_uid(course, "deadline", deadline.ID, deadline.Title, deadline.Due),
+			Summary:     course.get_title() + ": " + deadline.Title,
+			Description: deadline.Description,
+			Start:       due,
+			AllDay:      all_day,
+		})
+	}
+
+	assignments, err := get_course_assignments(course)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, assignment := range assignments {
+		due, all_day, err := parse_due(assignment.Due)
+		if err != nil {
+			return nil, fmt.Errorf("%v assignment '%v': %w", course.get_title(), assignment.Title, err)
+		}
+
+		event := CalendarEvent{
+			UID:     get_item_uid(course, "assignment", assignment.ID, assignment.Title, assignment.Due),
+			Summary: course.get_title() + ": " + assignment.Title,
+			Start:   due,
+			AllDay:  all_day,
+		}
+		if node := assignment.get_node(course); node != nil {
+			event.Description = node.get_breadcrumb()
+		}
+		if assignment.Status == ASSIGNMENT_DONE {
+			event.Summary += " (done)"
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func get_semester_events(semester *Node) ([]CalendarEvent, error) {
+
+	var events []CalendarEvent
+
+	for _, course := range semester.get_children() {
+		course_events, err := get_course_events(course)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, course_events...)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+
+	return events, nil
+}
+
+// format_ics renders events as an RFC 5545 calendar. Times are written as
+// floating local times, so they show at the same clock time anywhere.
+func format_ics(name string, events []CalendarEvent) string {
+
+	var lines []string
+
+	add := func(property, value string) {
+		lines = append(lines, property+":"+value)
+	}
+
+	stamp := time.Now().UTC().Format(ICS_DATETIME_FORMAT) + "Z"
+
+	add("BEGIN", "VCALENDAR")
+	add("VERSION", "2.0")
+	add("PRODID", "-//cmgr//cmgr//EN")
+	add("CALSCALE", "GREGORIAN")
+	add("X-WR-CALNAME", ics_escape(name))
+
+	for _, event := range events {
+		add("BEGIN", "VEVENT")
+		add("UID", event.UID)
+		add("DTSTAMP", stamp)
+
+		if event.AllDay {
+			add("DTSTART;VALUE=DATE", event.Start.Format(ICS_DATE_FORMAT))
+			add("DTEND;VALUE=DATE", event.Start.AddDate(0, 0, 1).Format(ICS_DATE_FORMAT))
+		} else {
+			add("DTSTART", event.Start.Format(ICS_DATETIME_FORMAT))
+			// Deadlines are points in time; DTEND must lie after DTSTART,
+			// so they get none.
+			if event.Duration > 0 {
+				add("DTEND", event.Start.Add(event.Duration).Format(ICS_DATETIME_FORMAT))
+			}
+		}
+
+		add("SUMMARY", ics_escape(event.Summary))
+		if event.Description != "" {
+			add("DESCRIPTION", ics_escape(event.Description))
+		}
+		add("END", "VEVENT")
+	}
+
+	add("END", "VCALENDAR")
+
+	var builder strings.Builder
+	for _, line := range lines {
+		builder.WriteString(ics_fold(line))
+		builder.WriteString("\r\n")
+	}
+
+	return builder.String()
+}
+
+func ics_escape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// ics_fold splits a content line into lines of at most 75 octets, without
+// breaking UTF-8 sequences; continuation lines start with a space.
+func ics_fold(line string) string {
+
+	var builder strings.Builder
+
+	limit := ICS_LINE_LENGTH
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		builder.WriteString(line[:cut])
+		builder.WriteString("\r\n ")
+		line = line[cut:]
+
+		// The leading space counts towards the continuation line's length.
+		limit = ICS_LINE_LENGTH - 1
+	}
+
+	builder.WriteString(line)
+
+	return builder.String()
+}
+
+func export_ics(output string) error {
+
+	semester := get_current_node(CFG_DEPTH_GROUP[0])
+	if semester == nil {
+		return fmt.Errorf("unable to find current %v", CFG_DEPTH_GROUP[0])
+	}
+
+	events, err := get_semester_events(semester)
+	if err != nil {
+		return err
+	}
+
+	calendar := format_ics(semester.get_title(), events)
+
+	if output == "" || output == "-" {
+		fmt.Print(calendar)
+		return nil
+	}
+
+	return os.WriteFile(output, []byte(calendar), 0644)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type IndexEntry struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Line  int    `json:"line"`
+}
+
+type IndexFile struct {
+	ModTime int64        `json:"mtime"`
+	Entries []IndexEntry `json:"entries"`
+}
+
+// Index maps every note file below the semester directory to the LaTeX
+// structure found in it.
+type Index map[string]*IndexFile
+
+type IndexData struct {
+	Version int   `json:"version"`
+	Files   Index `json:"files"`
+}
+
+var NoteIndex Index
+
+const (
+	INDEX_HEADING = "heading"
+	INDEX_LABEL   = "label"
+	INDEX_ENV     = "env"
+	INDEX_TERM    = "index"
+	INDEX_REF     = "ref"
+
+	// INDEX_VERSION is bumped whenever the parsed entries change so that
+	// stale indexes are rebuilt from scratch.
+	INDEX_VERSION = 2
+)
+
+var (
+	heading_pattern = regexp.MustCompile(`\\(part|chapter|section|subsection|subsubsection|paragraph)\*?(?:\[[^\]]*\])?\{([^}]*)\}`)
+	label_pattern   = regexp.MustCompile(`\\label\{([^}]*)\}`)
+	env_pattern     = regexp.MustCompile(`\\begin\{(\w+)\*?\}(?:\[([^\]]*)\])?`)
+	term_pattern    = regexp.MustCompile(`\\index\{([^}]*)\}`)
+	ref_pattern     = regexp.MustCompile(`\\(ref|eqref|cref|Cref)\{([^}]*)\}`)
+)
+
+func get_index_path() (string, error) {
+	root_dir, err := get_config_value(CFG_ROOT_FIELD)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root_dir, CFG_INDEX_FILE), nil
+}
+
+func load_index() (Index, error) {
+	index := Index{}
+
+	path, err := get_index_path()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The index is only a cache, so an unreadable or outdated one is simply
+	// rebuilt.
+	var stored IndexData
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Version != INDEX_VERSION || stored.Files == nil {
+		return index, nil
+	}
+
+	return stored.Files, nil
+}
+
+func save_index(index Index) error {
+	path, err := get_index_path()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(IndexData{Version: INDEX_VERSION, Files: index})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// update_index re-parses every note file whose modification time differs from
+// the one recorded in the index and drops files that no longer exist. Files
+// that cannot be read are reported and left out of the index.
+func update_index() (Index, error) {
+
+	index, err := load_index()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := get_search_root("")
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	seen := map[string]bool{}
+
+	err = walk_note_files(root, func(path string) error {
+		seen[path] = true
+
+		info, err := os.Stat(path)
+		if err != nil {
+			warn(fmt.Errorf("not indexing: %w", err))
+			delete(seen, path)
+			return nil
+		}
+
+		if entry, ok := index[path]; ok && entry.ModTime == info.ModTime().UnixNano() {
+			return nil
+		}
+
+		entries, err := parse_index_entries(path)
+		if err != nil {
+			warn(fmt.Errorf("not indexing %v: %w", path, err))
+			delete(seen, path)
+			return nil
+		}
+
+		index[path] = &IndexFile{ModTime: info.ModTime().UnixNano(), Entries: entries}
+		changed = true
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for path := range index {
+		if !seen[path] {
+			delete(index, path)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := save_index(index); err != nil {
+			return nil, err
+		}
+	}
+
+	return index, nil
+}
+
+func parse_index_entries(path string) ([]IndexEntry, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []IndexEntry
+
+	scanner := new_line_scanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strip_tex_comment(scanner.Text())
+
+		for _, match := range heading_pattern.FindAllStringSubmatch(text, -1) {
+			entries = append(entries, IndexEntry{INDEX_HEADING, match[1], match[2], line})
+		}
+		for _, match := range label_pattern.FindAllStringSubmatch(text, -1) {
+			entries = append(entries, IndexEntry{INDEX_LABEL, "label", match[1], line})
+		}
+		for _, match := range env_pattern.FindAllStringSubmatch(text, -1) {
+			if slices.Contains(CFG_INDEX_ENVIRONMENTS, match[1]) {
+				entries = append(entries, IndexEntry{INDEX_ENV, match[1], match[2], line})
+			}
+		}
+		for _, match := range term_pattern.FindAllStringSubmatch(text, -1) {
+			entries = append(entries, IndexEntry{INDEX_TERM, "index", match[1], line})
+		}
+		for _, match := range ref_pattern.FindAllStringSubmatch(text, -1) {
+			for _, label := range strings.Split(match[2], ",") {
+				entries = append(entries, IndexEntry{INDEX_REF, match[1], strings.TrimSpace(label), line})
+			}
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// query_index returns every entry of kind whose name matches name (if given)
+// and whose value contains term, as search hits sorted by path and line.
+func query_index(index Index, kind, name, term string) []SearchHit {
+
+	var hits []SearchHit
+
+	term = strings.ToLower(term)
+
+	for path, file := range index {
+		for _, entry := range file.Entries {
+			if entry.Kind != kind {
+				continue
+			}
+			if name != "" && entry.Name != name {
+				continue
+			}
+			if !strings.Contains(strings.ToLower(entry.Value), term) {
+				continue
+			}
+
+			hits = append(hits, SearchHit{
+				Node: find_node_by_path(path),
+				Path: path,
+				Line: entry.Line,
+				Text: fmt.Sprintf("[%v] %v", entry.Name, entry.Value),
+			})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Path != hits[j].Path {
+			return hits[i].Path < hits[j].Path
+		}
+		return hits[i].Line < hits[j].Line
+	})
+
+	return hits
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -42,6 +43,14 @@ func build_tree(parent *Node) (*Node, error) {
 			build_tree(node)
 		}
 
+		// The index only serves searches, so a broken one must not stop
+		// unrelated commands.
+		if index, err := update_index(); err != nil {
+			warn(fmt.Errorf("unable to update the note index: %w", err))
+		} else {
+			NoteIndex = index
+		}
+
 		return nil, nil
 	}
 
@@ -88,6 +97,15 @@ func build_tree(parent *Node) (*Node, error) {
 		node.set_id(id)
 		node.set_parent(parent)
 
+		if !file.IsDir() {
+			if metadata, err := read_note_metadata(node.get_path()); err == nil {
+				if metadata[CFG_META_ID] != "" {
+					node.set_id(metadata[CFG_META_ID])
+				}
+				node.set_date(metadata[CFG_META_DATE])
+			}
+		}
+
 		Nodes = append(Nodes, node)
 
 		build_tree(node)
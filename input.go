@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss/tree"
 )
@@ -17,7 +21,11 @@ func handle_input() {
 			continue
 		}
 
-		args = append(args, get_alias_group(arg))
+		if alias := get_alias_group(arg); alias != "" {
+			args = append(args, alias)
+		} else {
+			args = append(args, arg)
+		}
 	}
 
 	if len(args) > 0 {
@@ -37,9 +45,29 @@ func handle_input() {
 			}
 
 		case "new":
+			_, flags := parse_flags(os.Args[2:], "template", "date")
+
+			if _, ok := flags["next"]; ok {
+				if len(args) < 2 || args[1] != CFG_DEPTH_GROUP[len(CFG_DEPTH_GROUP)-1] {
+					log.Fatal("--next only applies to lectures")
+				}
+				node, err := create_next_lecture(flags["template"])
+				if err != nil {
+					log.Fatal(err)
+				}
+				fmt.Printf("Created %v '%v' for %v.\n", node.get_group(), node.get_title(), node.get_date())
+				return
+			}
+
+			if flags["date"] != "" {
+				if _, err := time.Parse(CFG_DATE_FORMAT, flags["date"]); err != nil {
+					log.Fatalf("invalid date '%v' (expected %v)", flags["date"], CFG_DATE_FORMAT)
+				}
+			}
+
 			if len(args) > 1 {
 				if valid_node_group(args[1]) {
-					_, err := node_creation_form(args[1])
+					_, err := node_creation_form(args[1], flags["template"], flags["date"])
 					if err != nil {
 						fmt.Println(err)
 						return
@@ -67,58 +95,349 @@ func handle_input() {
 				}
 			}
 
-		case "tree":
+		case "open":
+			var node *Node
+			var err error
+
 			if len(args) > 1 {
-				if valid_node_group(args[1]) {
-					current_node_title, err := get_config_value(CFG_CURRENT_NODE_PREFIX + args[1])
-					if err != nil {
-						log.Fatal(err)
-						return
-					}
-					tree := tree.New().Root(current_node_title)
-					for _, node := range Nodes {
-						if node.get_title() == current_node_title {
-							tree.Child(show_branch(node))
-							break
-						}
+				node, err = resolve_node(os.Args[2])
+			} else {
+				node, err = node_picker_form("Choose a node to open")
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if err := open_note(node); err != nil {
+				log.Fatal(err)
+			}
+
+		case "pick":
+			if err := fuzzy_finder_form(); err != nil {
+				log.Fatal(err)
+			}
+
+		case "grep":
+			positional, flags := parse_flags(os.Args[2:])
+			if len(positional) < 1 {
+				log.Fatal("usage: grep <pattern> [--course|--semester] [--regex] [--open]")
+			}
+
+			expression := regexp.QuoteMeta(strings.Join(positional, " "))
+			if _, ok := flags["regex"]; ok {
+				expression = strings.Join(positional, " ")
+			}
+
+			pattern, err := regexp.Compile("(?i)" + expression)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			scope := ""
+			for _, group := range []string{"course", "semester"} {
+				if _, ok := flags[group]; ok {
+					scope = group
+				}
+			}
+
+			root, err := get_search_root(scope)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			hits, err := search_notes(root, pattern)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if _, ok := flags["open"]; ok {
+				hit, err := search_hit_form(hits)
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := open_file(hit.Path, hit.Line); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+
+			print_search_hits(hits)
+
+			if len(hits) == 1 {
+				fmt.Println("\n1 match")
+			} else {
+				fmt.Printf("\n%v matches\n", len(hits))
+			}
+
+		case "find":
+			positional, flags := parse_flags(os.Args[2:], "label", "heading", "env", "index")
+			term := strings.Join(positional, " ")
+
+			for _, name := range []string{"label", "heading", "env", "index"} {
+				if value, ok := flags[name]; ok && value == "" {
+					log.Fatalf("--%v needs a value", name)
+				}
+			}
+
+			var hits []SearchHit
+
+			switch {
+			case flags["label"] != "":
+				hits = query_index(NoteIndex, INDEX_LABEL, "", flags["label"])
+			case flags["heading"] != "":
+				hits = query_index(NoteIndex, INDEX_HEADING, "", flags["heading"])
+			case flags["env"] != "":
+				hits = query_index(NoteIndex, INDEX_ENV, flags["env"], term)
+			case flags["index"] != "":
+				hits = query_index(NoteIndex, INDEX_TERM, "", flags["index"])
+			default:
+				log.Fatal("usage: find --label <name> | --heading <text> | --env <environment> [text] | --index <term>")
+			}
+
+			print_search_hits(hits)
+
+		case "refs":
+			var node *Node
+			var err error
+
+			if len(args) > 1 {
+				node, err = resolve_node(os.Args[2])
+			} else {
+				node, err = node_picker_form("Choose a node")
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			print_node_refs(node)
+
+		case "lint":
+			_, flags := parse_flags(os.Args[2:])
+
+			var courses []*Node
+
+			if _, ok := flags["all"]; ok {
+				for _, node := range Nodes {
+					if node.get_group() == "course" {
+						courses = append(courses, node)
 					}
-					fmt.Println(tree_style.Render(tree.String()))
 				}
+			} else if course := get_current_node("course"); course != nil {
+				courses = append(courses, course)
 			} else {
+				log.Fatal("unable to find current course")
+			}
 
-				tree := tree.New().Root("root")
+			found := false
 
+			for i, course := range courses {
+				if i > 0 {
+					fmt.Println()
+				}
+				issues := lint_node(course)
+				print_lint_issues(course, issues)
+				found = found || len(issues) > 0
+			}
+
+			if found {
+				os.Exit(1)
+			}
+
+		case "template":
+			if err := handle_template_command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+
+		case "todo":
+			if err := handle_todo_command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+
+		case "export":
+			positional, flags := parse_flags(os.Args[2:], "output")
+			if len(positional) < 1 || positional[0] != "ics" {
+				log.Fatal("usage: export ics [--output file]")
+			}
+
+			if err := export_ics(flags["output"]); err != nil {
+				log.Fatal(err)
+			}
+
+		case "schedule":
+			positional, flags := parse_flags(os.Args[2:])
+			if len(positional) < 1 || positional[0] != "preview" {
+				log.Fatal("usage: schedule preview [--all]")
+			}
+
+			if err := print_schedule_preview(flags["all"] == "true"); err != nil {
+				log.Fatal(err)
+			}
+
+		case "calendar":
+			_, flags := parse_flags(os.Args[2:])
+
+			group := CFG_DEPTH_GROUP[0]
+			if _, ok := flags["course"]; ok {
+				group = "course"
+			}
+
+			node := get_current_node(group)
+			if node == nil {
+				log.Fatalf("unable to find current %v", group)
+			}
+
+			print_calendar(node)
+
+		case "browse":
+			if err := run_browser(); err != nil {
+				log.Fatal(err)
+			}
+
+		case "status":
+			_, flags := parse_flags(os.Args[2:])
+			if err := print_status(get_status_report(), flags["json"] == "true"); err != nil {
+				log.Fatal(err)
+			}
+
+		case "log":
+			var node *Node
+			var err error
+
+			if len(args) > 1 {
+				node, err = resolve_node(os.Args[2])
+			} else {
+				node, err = node_picker_form("Choose a node")
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if err := print_node_log(node); err != nil {
+				log.Fatal(err)
+			}
+
+		case "build":
+			node := get_current_node(CFG_DEPTH_GROUP[len(CFG_DEPTH_GROUP)-1])
+			if len(args) > 1 && valid_node_group(args[1]) {
+				node = get_current_node(args[1])
+			}
+			if node == nil {
+				log.Fatal("unable to find current node")
+			}
+
+			result, err := build_node(node)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			fmt.Println(result)
+
+		case "watch":
+			course := get_current_node("course")
+
+			if working_dir, err := os.Getwd(); err == nil {
+				if node := find_node_by_path(working_dir); node != nil && node.get_ancestor("course") != nil {
+					course = node.get_ancestor("course")
+				}
+			}
+
+			if course == nil {
+				log.Fatal("unable to find current course")
+			}
+
+			if err := watch(course); err != nil {
+				log.Fatal(err)
+			}
+
+		case "tree":
+			positional, flags := parse_flags(os.Args[2:], "format", "depth", "columns", "group", "modified-since", "tag")
+
+			options, err := parse_tree_options(flags)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var roots []*Node
+			root_title := "root"
+
+			if len(positional) > 0 {
+				group := get_alias_group(positional[0])
+				if !valid_node_group(group) {
+					log.Fatalf("invalid group: %v", positional[0])
+				}
+				node := get_current_node(group)
+				if node == nil {
+					log.Fatalf("no current %v", group)
+				}
+				roots = []*Node{node}
+				root_title = node.get_title()
+			} else {
 				for _, node := range Nodes {
 					if node.get_depth() == 0 {
-						tr, err := show_branch(node)
-						if err != nil {
-							log.Fatal(err)
-							return
-						}
-
-						tree.Child(tr)
+						roots = append(roots, node)
 					}
 				}
+			}
 
-				fmt.Println(tree_style.Render(tree.String()))
+			if format := flags["format"]; format != "" {
+				output, err := format_tree(roots, format, options.Levels)
+				if err != nil {
+					log.Fatal(err)
+				}
+				fmt.Print(output)
+				return
 			}
+
+			tree := tree.New().Root(root_title)
+
+			for _, node := range roots {
+				if options.visible(node) {
+					tree.Child(render_branch(node, options, options.Levels))
+				}
+			}
+
+			fmt.Println(tree_style.Render(tree.String()))
 		}
 	}
 
 }
 
-func show_branch(node *Node) (*tree.Tree, error) {
+// parse_flags splits raw arguments into positionals and --flags. Flags named
+// in value_flags consume the following argument unless it is another flag
+// (or take --flag=value), and map to "" without one; all others are boolean
+// and map to "true".
+func parse_flags(raw []string, value_flags ...string) ([]string, map[string]string) {
 
-	t := tree.New().Root(fmt.Sprintf("%v: %v", node.get_group(), node.get_title()))
+	var positional []string
+	flags := map[string]string{}
 
-	for _, child := range node.Children {
-		child_tree, err := show_branch(child)
-		if err != nil {
-			return nil, err
+	for i := 0; i < len(raw); i++ {
+		arg := raw[i]
+
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, has_value := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+
+		if !has_value {
+			value = "true"
+			if slices.Contains(value_flags, name) {
+				value = ""
+				if i+1 < len(raw) && !strings.HasPrefix(raw[i+1], "--") {
+					value = raw[i+1]
+					i++
+				}
+			}
 		}
 
-		t.Child(child_tree)
+		flags[name] = value
 	}
 
-	return t, nil
+	return positional, flags
+}
+
+func show_branch(node *Node) (*tree.Tree, error) {
+	return render_branch(node, &TreeOptions{}, 0), nil
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
 func read_json_value(path, field string) (string, error) {
@@ -28,7 +29,35 @@ func read_json_value(path, field string) (string, error) {
 	return "", fmt.Errorf("%v is not a field", field)
 }
 
+func read_json_field(path, field string) (interface{}, error) {
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed_data map[string]interface{}
+	err = json.Unmarshal([]byte(data), &parsed_data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if result, ok := parsed_data[field]; ok {
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("%v is not a field", field)
+}
+
 func write_json_value(path, field, value string) error {
+	return write_json_field(path, field, value)
+}
+
+// write_json_field sets field of the JSON object at path to any value that
+// encoding/json can marshal.
+func write_json_field(path, field string, value interface{}) error {
 
 	data, err := os.ReadFile(path)
 
@@ -60,4 +89,36 @@ func write_json_value(path, field, value string) error {
 	return nil
 }
 
+// decode_json_field decodes field of the JSON object at path into target.
+func decode_json_field(path, field string, target interface{}) error {
 
+	value, err := read_json_field(path, field)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("invalid %v in %s: %w", field, path, err)
+	}
+
+	return nil
+}
+
+// decode_course_field decodes field of the course's info.json into target. A
+// course without the field leaves target untouched, e.g. a course without
+// deadlines simply has none.
+func decode_course_field(course *Node, field string, target interface{}) error {
+
+	info_path := filepath.Join(course.get_path(), CFG_INFO_FILENAME+".json")
+
+	if _, err := read_json_field(info_path, field); err != nil {
+		return nil
+	}
+
+	return decode_json_field(info_path, field, target)
+}
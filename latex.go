@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	metadata_pattern = regexp.MustCompile(`^%\s*([A-Z][A-Z_-]*):\s*(.*?)\s*$`)
+	command_pattern  = regexp.MustCompile(`\\[a-zA-Z@]+\*?|[{}\[\]$&^_~\\]`)
+)
+
+// strip_tex_comment removes everything from the first unescaped % onwards.
+func strip_tex_comment(line string) string {
+	backslashes := 0
+
+	for i, r := range line {
+		switch r {
+		case '\\':
+			backslashes++
+			continue
+		case '%':
+			if backslashes%2 == 0 {
+				return line[:i]
+			}
+		}
+		backslashes = 0
+	}
+
+	return line
+}
+
+// new_line_scanner returns a scanner over the lines of file that accepts
+// lines up to CFG_MAX_LINE_LENGTH rather than bufio's default 64KB.
+func new_line_scanner(file *os.File) *bufio.Scanner {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), CFG_MAX_LINE_LENGTH)
+	return scanner
+}
+
+// walk_note_files calls fn for every note file below root, skipping build
+// output and hidden directories. Entries that cannot be read are reported
+// and skipped; only an unreadable root is an error.
+func walk_note_files(root string, fn func(path string) error) error {
+	return filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if path == root || entry == nil {
+				return err
+			}
+			warn(err)
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if entry.IsDir() {
+			if entry.Name() == CFG_BUILD_DIR || (path != root && strings.HasPrefix(entry.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(path) != CFG_NOTE_FILETYPE {
+			return nil
+		}
+
+		return fn(path)
+	})
+}
+
+// read_note_metadata collects the "% KEY: value" comment lines of a note,
+// e.g. "% DATE: 2024-09-03" or "% TAGS: exam, proofs".
+func read_note_metadata(path string) (map[string]string, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	metadata := map[string]string{}
+
+	scanner := new_line_scanner(file)
+	for scanner.Scan() {
+		if match := metadata_pattern.FindStringSubmatch(scanner.Text()); match != nil {
+			metadata[match[1]] = match[2]
+		}
+	}
+
+	return metadata, scanner.Err()
+}
+
+// write_note_metadata sets "% KEY: value" in the note at path, replacing an
+// existing line for key or adding one at the top of the file.
+func write_note_metadata(path, key, value string) error {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	line := "% " + key + ": " + value
+	lines := strings.Split(string(data), "\n")
+	found := false
+
+	for i, existing := range lines {
+		if match := metadata_pattern.FindStringSubmatch(existing); match != nil && match[1] == key {
+			lines[i] = line
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		lines = append([]string{line}, lines...)
+	}
+
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), info.Mode())
+}
+
+// get_metadata_list splits a comma separated metadata value.
+func get_metadata_list(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// count_words approximates the number of words in a note by ignoring
+// comments, control sequences and LaTeX special characters.
+func count_words(path string) (int, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := 0
+
+	scanner := new_line_scanner(file)
+	for scanner.Scan() {
+		text := command_pattern.ReplaceAllString(strip_tex_comment(scanner.Text()), " ")
+
+		for _, word := range strings.Fields(text) {
+			if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+				count++
+			}
+		}
+	}
+
+	return count, scanner.Err()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	LINT_DUPLICATE = "duplicate label"
+	LINT_UNDEFINED = "undefined reference"
+)
+
+type LintIssue struct {
+	Kind  string
+	Label string
+	Hits  []SearchHit
+}
+
+// lint_node reports labels defined more than once and references to labels
+// that are never defined within the node's subtree.
+func lint_node(node *Node) []LintIssue {
+
+	graph := build_ref_graph(NoteIndex, node.get_path())
+
+	var issues []LintIssue
+
+	for label, definitions := range graph.Labels {
+		if len(definitions) > 1 {
+			issues = append(issues, LintIssue{LINT_DUPLICATE, label, definitions})
+		}
+	}
+
+	undefined := map[string][]SearchHit{}
+
+	for _, edges := range graph.Outgoing {
+		for _, edge := range edges {
+			if edge.To != nil {
+				continue
+			}
+			undefined[edge.Label] = append(undefined[edge.Label], SearchHit{
+				Node: edge.From,
+				Path: edge.Path,
+				Line: edge.Line,
+				Text: edge.Label,
+			})
+		}
+	}
+
+	for label, hits := range undefined {
+		sort.Slice(hits, func(i, j int) bool {
+			if hits[i].Path != hits[j].Path {
+				return hits[i].Path < hits[j].Path
+			}
+			return hits[i].Line < hits[j].Line
+		})
+		issues = append(issues, LintIssue{LINT_UNDEFINED, label, hits})
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Kind != issues[j].Kind {
+			return issues[i].Kind < issues[j].Kind
+		}
+		return issues[i].Label < issues[j].Label
+	})
+
+	return issues
+}
+
+func print_lint_issues(node *Node, issues []LintIssue) {
+
+	fmt.Println(bold_style.Render(node.get_breadcrumb()))
+
+	if len(issues) < 1 {
+		fmt.Println("  no issues")
+		return
+	}
+
+	for _, issue := range issues {
+		fmt.Printf("  %v '%v'\n", issue.Kind, issue.Label)
+		for _, hit := range issue.Hits {
+			fmt.Printf("    %v (%v:%v)\n", describe_ref_node(hit.Node), filepath.Base(hit.Path), hit.Line)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"log"
+	"os"
 )
 
+// CapturedOutput receives warnings and the output of hooks instead of the
+// terminal while it is set, e.g. while the browser owns the screen.
+var CapturedOutput *bytes.Buffer
+
 func main() {
 
 	if _, err := build_tree(nil); err != nil {
@@ -38,3 +44,12 @@ func main() {
 
 	handle_input()
 }
+
+// warn reports a problem that does not stop the current command.
+func warn(err error) {
+	if CapturedOutput != nil {
+		fmt.Fprintln(CapturedOutput, "warning:", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "warning:", err)
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -14,6 +16,7 @@ type Node struct {
 	Title    string
 	Path     string
 	Id       string
+	Date     string
 	Parent   *Node
 	Children []*Node
 }
@@ -57,6 +60,9 @@ func (n *Node) set_id(id string) {
 	}
 }
 
+func (n *Node) get_date() string     { return n.Date }
+func (n *Node) set_date(date string) { n.Date = date }
+
 func (n *Node) get_depth() int { return CFG_GROUP_DEPTH[n.Group] }
 
 func (n *Node) get_parent() *Node     { return n.Parent }
@@ -89,7 +95,9 @@ func (n *Node) set_parent(parent *Node) error {
 	return nil
 }
 
-func create_node(group, title string) (*Node, error) {
+// create_node creates a node of group below the current parent. Lectures
+// are dated with date, or today if it is empty.
+func create_node(group, title, template_name, date string) (*Node, error) {
 
 	root_dir, err := get_config_value(CFG_ROOT_FIELD)
 	if err != nil {
@@ -123,15 +131,49 @@ func create_node(group, title string) (*Node, error) {
 		return nil, err
 	}
 
-	template_path, err := find_path("file", filepath.Join(root_dir, CFG_TEMPLATE_DIR), group)
+	if CFG_GROUP_DEPTH[group] == len(CFG_GROUP_DEPTH)-1 {
+		if date == "" {
+			date = time.Now().Format(CFG_DATE_FORMAT)
+		}
+		node.set_date(date)
+	}
+
+	template_path, err := find_named_template(node.get_parent(), group, template_name)
 	if err != nil {
+		detach_node(node)
 		return nil, err
 	}
 
-	if err := apply_template(template_path, node); err != nil {
+	if _, err := os.Stat(node.get_path()); err == nil {
+		detach_node(node)
+		return nil, fmt.Errorf("file %s already exists", node.get_path())
+	}
+
+	if err := run_pre_hooks(HOOK_CREATE, node, nil); err != nil {
+		detach_node(node)
+		return nil, err
+	}
+
+	// Lectures keep their id in the note itself, since they have no
+	// info.json.
+	created, err := apply_template(template_path, node)
+	if err == nil && CFG_GROUP_DEPTH[group] == len(CFG_GROUP_DEPTH)-1 {
+		err = write_note_metadata(node.get_path(), CFG_META_ID, node.get_id())
+		if err == nil {
+			err = write_note_metadata(node.get_path(), CFG_META_DATE, node.get_date())
+		}
+	}
+
+	// A template, post_create command or metadata write that fails leaves no
+	// node behind.
+	if err != nil {
+		os.RemoveAll(node.get_path())
+		detach_node(node)
 		return nil, err
 	}
 
+	track_change(created...)
+
 	set_config_value(CFG_CURRENT_NODE_PREFIX+group, title)
 
 	for i := CFG_GROUP_DEPTH[group] + 1; i < len(CFG_GROUP_DEPTH); i++ {
@@ -140,7 +182,7 @@ func create_node(group, title string) (*Node, error) {
 
 	Nodes = append(Nodes, node)
 
-	populate_note_fields(node)
+	run_post_hooks(HOOK_CREATE, node, nil)
 
 	return node, nil
 }
@@ -221,3 +263,335 @@ func get_struct_field_names(data interface{}) []string {
 
 	return field_names
 }
+
+func (n *Node) get_ancestor(group string) *Node {
+	for current := n; current != nil; current = current.get_parent() {
+		if current.get_group() == group {
+			return current
+		}
+	}
+	return nil
+}
+
+func (n *Node) get_breadcrumb() string {
+	var titles []string
+	for current := n; current != nil; current = current.get_parent() {
+		titles = append([]string{current.get_title()}, titles...)
+	}
+	return strings.Join(titles, CFG_BREADCRUMB_SEPARATOR)
+}
+
+// get_open_path returns the file an editor should open for the node: leaf
+// notes are opened directly, directory nodes through their composite file.
+func (n *Node) get_open_path() (string, error) {
+	if filepath.Ext(n.get_path()) == CFG_NOTE_FILETYPE {
+		return n.get_path(), nil
+	}
+	return get_composite_file(n.get_path())
+}
+
+func find_node_by_path(path string) *Node {
+
+	var found *Node
+
+	for _, node := range Nodes {
+		rel, err := filepath.Rel(node.get_path(), path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if found == nil || node.get_depth() > found.get_depth() {
+			found = node
+		}
+	}
+
+	return found
+}
+
+// resolve_node finds a node by id or by a path on disk, which may be relative
+// to the working directory and may point at any file inside the node.
+func resolve_node(arg string) (*Node, error) {
+	for _, node := range Nodes {
+		if node.get_id() == arg {
+			return node, nil
+		}
+	}
+
+	path, err := filepath.Abs(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if node := find_node_by_path(path); node != nil {
+			return node, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no node matches '%v'", arg)
+}
+
+func get_current_node(group string) *Node {
+
+	var current *Node
+
+	for depth := 0; depth <= CFG_GROUP_DEPTH[group]; depth++ {
+		title, err := get_config_value(CFG_CURRENT_NODE_PREFIX + CFG_DEPTH_GROUP[depth])
+		if err != nil || title == "" {
+			return nil
+		}
+
+		var next *Node
+
+		for _, node := range Nodes {
+			if node.get_depth() == depth && node.get_parent() == current && node.get_title() == title {
+				next = node
+				break
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		current = next
+	}
+
+	return current
+}
+
+// remove_node deletes the node from disk, its parent's composite file and the
+// in-memory tree.
+func remove_node(node *Node) error {
+	if err := run_pre_hooks(HOOK_REMOVE, node, nil); err != nil {
+		return err
+	}
+
+	if err := remove_from_parent_input_file(node); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(node.get_path()); err != nil {
+		return err
+	}
+
+	track_change(node.get_path())
+
+	detach_node(node)
+
+	var remaining []*Node
+
+	for _, listnode := range Nodes {
+		if listnode.get_ancestor(node.get_group()) != node {
+			remaining = append(remaining, listnode)
+		}
+	}
+
+	Nodes = remaining
+
+	run_post_hooks(HOOK_REMOVE, node, nil)
+
+	return nil
+}
+
+// detach_node removes node from its parent's children.
+func detach_node(node *Node) {
+	if parent := node.get_parent(); parent != nil {
+		for i, child := range parent.Children {
+			if child == node {
+				parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// set_currents_to makes node and all of its ancestors current and clears the
+// currents below it.
+func set_currents_to(node *Node) error {
+	if err := run_pre_hooks(HOOK_SET_CURRENT, node, nil); err != nil {
+		return err
+	}
+
+	for current := node; current != nil; current = current.get_parent() {
+		if err := set_config_value(CFG_CURRENT_NODE_PREFIX+current.get_group(), current.get_title()); err != nil {
+			return err
+		}
+	}
+
+	for i := node.get_depth() + 1; i < len(CFG_GROUP_DEPTH); i++ {
+		if err := set_config_value(CFG_CURRENT_NODE_PREFIX+CFG_DEPTH_GROUP[i], ""); err != nil {
+			return err
+		}
+	}
+
+	run_post_hooks(HOOK_SET_CURRENT, node, nil)
+
+	return nil
+}
+
+// rename_node renames the node on disk and in the tree. Its entry in the
+// parent's composite file and every \input below it follow the new path.
+func rename_node(node *Node, title string) error {
+
+	title = strings.TrimSpace(title)
+	if title == "" || strings.ContainsRune(title, filepath.Separator) {
+		return fmt.Errorf("invalid title '%v'", title)
+	}
+	if title == node.get_title() {
+		return nil
+	}
+
+	for _, sibling := range get_siblings(node) {
+		if sibling.get_title() == title {
+			return fmt.Errorf("%v already exists: '%v'", node.get_group(), title)
+		}
+	}
+
+	old_path, old_title := node.get_path(), node.get_title()
+
+	new_path := filepath.Join(filepath.Dir(old_path), title)
+	if filepath.Ext(old_path) == CFG_NOTE_FILETYPE {
+		new_path += CFG_NOTE_FILETYPE
+	}
+
+	if _, err := os.Lstat(new_path); err == nil {
+		return fmt.Errorf("file %s already exists", new_path)
+	}
+
+	if err := run_pre_hooks(HOOK_RENAME, node, map[string]string{"NEW_TITLE": title}); err != nil {
+		return err
+	}
+
+	was_current := get_current_node(node.get_group()) == node
+
+	if err := os.Rename(old_path, new_path); err != nil {
+		return err
+	}
+
+	track_change(old_path, new_path)
+
+	relocate_nodes(old_path, new_path)
+	node.set_title(title)
+
+	if parent := node.get_parent(); parent != nil {
+		if composite_file, err := get_composite_file(parent.get_path()); err == nil && composite_file != "" {
+			if err := relink_input_file(composite_file, old_path, new_path, old_title, title); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := relink_input_files(node, old_path); err != nil {
+		return err
+	}
+
+	if filepath.Ext(new_path) != CFG_NOTE_FILETYPE {
+		if err := write_json_value(filepath.Join(new_path, CFG_INFO_FILENAME+".json"), "title", title); err != nil {
+			return err
+		}
+	}
+
+	if was_current {
+		set_config_value(CFG_CURRENT_NODE_PREFIX+node.get_group(), title)
+	}
+
+	run_post_hooks(HOOK_RENAME, node, map[string]string{"OLD_TITLE": old_title, "OLD_PATH": old_path})
+
+	return nil
+}
+
+// move_node moves the node below a new parent of the group above it,
+// updating both parents' composite files and every \input below the node.
+func move_node(node, parent *Node) error {
+
+	if parent.get_depth() != node.get_depth()-1 {
+		return fmt.Errorf("a %v can only be moved into a %v", node.get_group(), CFG_DEPTH_GROUP[node.get_depth()-1])
+	}
+	if parent == node.get_parent() {
+		return nil
+	}
+
+	for _, child := range parent.get_children() {
+		if child.get_title() == node.get_title() {
+			return fmt.Errorf("%v already exists in %v: '%v'", node.get_group(), parent.get_title(), node.get_title())
+		}
+	}
+
+	group_path := filepath.Join(parent.get_path(), node.get_group())
+	old_path := node.get_path()
+	new_path := filepath.Join(group_path, filepath.Base(old_path))
+
+	if _, err := os.Lstat(new_path); err == nil {
+		return fmt.Errorf("file %s already exists", new_path)
+	}
+
+	if err := run_pre_hooks(HOOK_MOVE, node, map[string]string{"NEW_PARENT_PATH": parent.get_path()}); err != nil {
+		return err
+	}
+
+	was_current := get_current_node(node.get_group()) == node
+
+	if err := remove_from_parent_input_file(node); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(group_path, os.ModePerm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(old_path, new_path); err != nil {
+		return err
+	}
+
+	track_change(old_path, new_path)
+
+	relocate_nodes(old_path, new_path)
+
+	detach_node(node)
+	if err := node.set_parent(parent); err != nil {
+		return err
+	}
+
+	if err := relink_input_files(node, old_path); err != nil {
+		return err
+	}
+
+	if err := add_children_to_input_file(parent); err != nil {
+		return err
+	}
+
+	if was_current {
+		for current := parent; current != nil; current = current.get_parent() {
+			set_config_value(CFG_CURRENT_NODE_PREFIX+current.get_group(), current.get_title())
+		}
+	}
+
+	run_post_hooks(HOOK_MOVE, node, map[string]string{"OLD_PATH": old_path})
+
+	return nil
+}
+
+func get_siblings(node *Node) []*Node {
+	if parent := node.get_parent(); parent != nil {
+		return parent.get_children()
+	}
+
+	var siblings []*Node
+	for _, other := range Nodes {
+		if other.get_depth() == node.get_depth() {
+			siblings = append(siblings, other)
+		}
+	}
+	return siblings
+}
+
+// relocate_nodes points every node at or below old_path to new_path.
+func relocate_nodes(old_path, new_path string) {
+	for _, node := range Nodes {
+		if node.get_path() == old_path {
+			node.set_path(new_path)
+		} else if rest, found := strings.CutPrefix(node.get_path(), old_path+string(filepath.Separator)); found {
+			node.set_path(filepath.Join(new_path, rest))
+		}
+	}
+}
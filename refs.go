@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type RefEdge struct {
+	From  *Node
+	To    *Node
+	Label string
+	Path  string
+	Line  int
+}
+
+// RefGraph holds every \label definition and \ref-style reference below a
+// directory, with each reference resolved to the node defining its label.
+type RefGraph struct {
+	Labels   map[string][]SearchHit
+	Outgoing map[*Node][]RefEdge
+	Incoming map[*Node][]RefEdge
+}
+
+func build_ref_graph(index Index, root string) *RefGraph {
+
+	graph := &RefGraph{
+		Labels:   map[string][]SearchHit{},
+		Outgoing: map[*Node][]RefEdge{},
+		Incoming: map[*Node][]RefEdge{},
+	}
+
+	paths := get_index_paths(index, root)
+
+	for _, path := range paths {
+		for _, entry := range index[path].Entries {
+			if entry.Kind != INDEX_LABEL {
+				continue
+			}
+			graph.Labels[entry.Value] = append(graph.Labels[entry.Value], SearchHit{
+				Node: find_node_by_path(path),
+				Path: path,
+				Line: entry.Line,
+				Text: entry.Value,
+			})
+		}
+	}
+
+	for _, path := range paths {
+		from := find_node_by_path(path)
+
+		for _, entry := range index[path].Entries {
+			if entry.Kind != INDEX_REF {
+				continue
+			}
+
+			edge := RefEdge{From: from, Label: entry.Value, Path: path, Line: entry.Line}
+
+			if definitions := graph.Labels[entry.Value]; len(definitions) > 0 {
+				edge.To = definitions[0].Node
+				graph.Incoming[edge.To] = append(graph.Incoming[edge.To], edge)
+			}
+
+			graph.Outgoing[from] = append(graph.Outgoing[from], edge)
+		}
+	}
+
+	return graph
+}
+
+// get_index_paths returns the indexed files below root in a stable order.
+func get_index_paths(index Index, root string) []string {
+	var paths []string
+
+	for path := range index {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// get_ref_scope returns the directory whose labels share a namespace with the
+// node's: its course, since lectures are all \input into the course master.
+func get_ref_scope(node *Node) string {
+	if course := node.get_ancestor("course"); course != nil {
+		return course.get_path()
+	}
+	return node.get_path()
+}
+
+func contains_node(outer, inner *Node) bool {
+	return inner != nil && inner.get_ancestor(outer.get_group()) == outer
+}
+
+// get_node_refs returns the references leaving node's subtree and the
+// references from elsewhere into labels defined inside it.
+func (g *RefGraph) get_node_refs(node *Node) ([]RefEdge, []RefEdge) {
+
+	var outgoing, incoming []RefEdge
+
+	for from, edges := range g.Outgoing {
+		if !contains_node(node, from) {
+			continue
+		}
+		for _, edge := range edges {
+			if !contains_node(node, edge.To) {
+				outgoing = append(outgoing, edge)
+			}
+		}
+	}
+
+	for to, edges := range g.Incoming {
+		if !contains_node(node, to) {
+			continue
+		}
+		for _, edge := range edges {
+			if !contains_node(node, edge.From) {
+				incoming = append(incoming, edge)
+			}
+		}
+	}
+
+	sort_ref_edges(outgoing)
+	sort_ref_edges(incoming)
+
+	return outgoing, incoming
+}
+
+func sort_ref_edges(edges []RefEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Path != edges[j].Path {
+			return edges[i].Path < edges[j].Path
+		}
+		return edges[i].Line < edges[j].Line
+	})
+}
+
+func describe_ref_node(node *Node) string {
+	if node == nil {
+		return "undefined"
+	}
+	return node.get_breadcrumb()
+}
+
+func print_node_refs(node *Node) {
+
+	graph := build_ref_graph(NoteIndex, get_ref_scope(node))
+	outgoing, incoming := graph.get_node_refs(node)
+
+	fmt.Println(bold_style.Render(node.get_breadcrumb()))
+
+	fmt.Printf("\nOutgoing (%v)\n", len(outgoing))
+	for _, edge := range outgoing {
+		fmt.Printf("  %v:%v: %v → %v\n", filepath.Base(edge.Path), edge.Line, edge.Label, describe_ref_node(edge.To))
+	}
+
+	fmt.Printf("\nIncoming (%v)\n", len(incoming))
+	for _, edge := range incoming {
+		fmt.Printf("  %v ← %v (%v:%v)\n", edge.Label, describe_ref_node(edge.From), filepath.Base(edge.Path), edge.Line)
+	}
+}
+
+// get_removal_warning describes the labels defined in node that are still
+// referenced from outside of it, or returns "" if removing it is safe.
+func get_removal_warning(node *Node) string {
+	if node == nil {
+		return ""
+	}
+
+	graph := build_ref_graph(NoteIndex, get_ref_scope(node))
+	_, incoming := graph.get_node_refs(node)
+
+	if len(incoming) < 1 {
+		return ""
+	}
+
+	var lines []string
+	for _, edge := range incoming {
+		lines = append(lines, fmt.Sprintf("%v ← %v", edge.Label, describe_ref_node(edge.From)))
+	}
+
+	return fmt.Sprintf("Warning: labels defined here are referenced elsewhere:\n%v", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A course's info.json may describe when it meets, e.g.
+//
+//	"schedule": {
+//	  "start": "2024-09-02",
+//	  "end": "2024-12-13",
+//	  "meetings": [{"day": "Mon", "time": "10:00", "duration": 90}, {"day": "Wed", "time": "10:00"}],
+//	  "holidays": ["2024-11-28", "2024-10-14..2024-10-18"],
+//	  "title": "Lecture %%number%%"
+//	}
+//
+// Every meeting between start and end that is not a holiday is a slot.
+// Slots are numbered from 1, and title names the lecture for a slot. A
+// meeting's duration is given in minutes.
+
+type Meeting struct {
+	Day      string `json:"day"`
+	Time     string `json:"time"`
+	Duration int    `json:"duration"`
+}
+
+type Schedule struct {
+	Start    string    `json:"start"`
+	End      string    `json:"end"`
+	Meetings []Meeting `json:"meetings"`
+	Holidays []string  `json:"holidays"`
+	Title    string    `json:"title"`
+}
+
+type ScheduleSlot struct {
+	Number   int
+	Time     time.Time
+	Duration time.Duration
+	Lecture  *Node
+}
+
+func get_course_schedule(course *Node) (*Schedule, error) {
+
+	info_path := filepath.Join(course.get_path(), CFG_INFO_FILENAME+".json")
+
+	schedule := &Schedule{}
+	if err := decode_json_field(info_path, CFG_SCHEDULE_FIELD, schedule); err != nil {
+		return nil, fmt.Errorf("no schedule for %v: %w", course.get_title(), err)
+	}
+
+	if schedule.Title == "" {
+		schedule.Title = CFG_SCHEDULE_TITLE
+	}
+
+	return schedule, nil
+}
+
+func parse_weekday(day string) (time.Weekday, error) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := weekday.String()
+		if len(day) >= 3 && strings.HasPrefix(strings.ToLower(name), strings.ToLower(day)) {
+			return weekday, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day '%v'", day)
+}
+
+// is_holiday reports whether date is listed in holidays, either on its own
+// or inside a "from..to" range.
+func is_holiday(date string, holidays []string) bool {
+	for _, holiday := range holidays {
+		if from, to, found := strings.Cut(holiday, ".."); found {
+			if date >= strings.TrimSpace(from) && date <= strings.TrimSpace(to) {
+				return true
+			}
+		} else if date == strings.TrimSpace(holiday) {
+			return true
+		}
+	}
+	return false
+}
+
+// get_schedule_slots lists every slot of the schedule in order, attaching
+// the lecture below course that is dated on the slot's day, if any.
+func get_schedule_slots(schedule *Schedule, course *Node) ([]ScheduleSlot, error) {
+
+	start, err := time.ParseInLocation(CFG_DATE_FORMAT, schedule.Start, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule start '%v'", schedule.Start)
+	}
+
+	end, err := time.ParseInLocation(CFG_DATE_FORMAT, schedule.End, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule end '%v'", schedule.End)
+	}
+
+	meetings := map[time.Weekday][]Meeting{}
+
+	for _, meeting := range schedule.Meetings {
+		weekday, err := parse_weekday(meeting.Day)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := time.Parse(CFG_TIME_FORMAT, meeting.Time); err != nil {
+			return nil, fmt.Errorf("invalid meeting time '%v'", meeting.Time)
+		}
+
+		if meeting.Duration == 0 {
+			meeting.Duration = CFG_MEETING_DURATION
+		}
+
+		meetings[weekday] = append(meetings[weekday], meeting)
+	}
+
+	if len(meetings) < 1 {
+		return nil, fmt.Errorf("the schedule has no meetings")
+	}
+
+	lectures := map[string][]*Node{}
+	if course != nil {
+		dated, _ := get_dated_lectures(course)
+		for _, lecture := range dated {
+			lectures[lecture.get_date()] = append(lectures[lecture.get_date()], lecture)
+		}
+	}
+
+	var slots []ScheduleSlot
+
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format(CFG_DATE_FORMAT)
+		if is_holiday(date, schedule.Holidays) {
+			continue
+		}
+
+		for _, meeting := range meetings[day.Weekday()] {
+			start, _ := time.ParseInLocation(CFG_DATE_FORMAT+" "+CFG_TIME_FORMAT, date+" "+meeting.Time, time.Local)
+
+			slot := ScheduleSlot{
+				Number:   len(slots) + 1,
+				Time:     start,
+				Duration: time.Duration(meeting.Duration) * time.Minute,
+			}
+
+			// Several meetings on one day take that day's lectures in order.
+			if taken := lectures[date]; len(taken) > 0 {
+				slot.Lecture = taken[0]
+				lectures[date] = taken[1:]
+			}
+
+			slots = append(slots, slot)
+		}
+	}
+
+	return slots, nil
+}
+
+func (s *Schedule) get_slot_title(slot ScheduleSlot) string {
+	title := strings.ReplaceAll(s.Title, CFG_REPLACE_MARKER+"number"+CFG_REPLACE_MARKER, fmt.Sprint(slot.Number))
+	return strings.ReplaceAll(title, CFG_REPLACE_MARKER+"date"+CFG_REPLACE_MARKER, slot.Time.Format(CFG_DATE_FORMAT))
+}
+
+// get_next_slot returns the first slot from today on that has no lecture.
+func get_next_slot(slots []ScheduleSlot) (ScheduleSlot, error) {
+	today := time.Now().Format(CFG_DATE_FORMAT)
+
+	for _, slot := range slots {
+		if slot.Lecture == nil && slot.Time.Format(CFG_DATE_FORMAT) >= today {
+			return slot, nil
+		}
+	}
+
+	return ScheduleSlot{}, fmt.Errorf("no scheduled meetings left")
+}
+
+// create_next_lecture creates the lecture for the next free slot of the
+// current course's schedule in the current section.
+func create_next_lecture(template_name string) (*Node, error) {
+
+	course := get_current_node("course")
+	if course == nil {
+		return nil, fmt.Errorf("unable to find current course")
+	}
+
+	schedule, err := get_course_schedule(course)
+	if err != nil {
+		return nil, err
+	}
+
+	slots, err := get_schedule_slots(schedule, course)
+	if err != nil {
+		return nil, err
+	}
+
+	slot, err := get_next_slot(slots)
+	if err != nil {
+		return nil, err
+	}
+
+	group := CFG_DEPTH_GROUP[len(CFG_DEPTH_GROUP)-1]
+	title := schedule.get_slot_title(slot)
+
+	parent := get_current_node(CFG_DEPTH_GROUP[len(CFG_DEPTH_GROUP)-2])
+	if parent == nil || parent.get_ancestor("course") != course {
+		return nil, fmt.Errorf("unable to find current %v in %v", CFG_DEPTH_GROUP[len(CFG_DEPTH_GROUP)-2], course.get_title())
+	}
+
+	for _, child := range parent.get_children() {
+		if child.get_title() == title {
+			return nil, fmt.Errorf("%v already exists: '%v'", group, title)
+		}
+	}
+
+	node, err := create_node(group, title, template_name, slot.Time.Format(CFG_DATE_FORMAT))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := add_children_to_input_file(parent); err != nil {
+		return node, err
+	}
+
+	return node, commit_changes(fmt.Sprintf("Add %v '%v' to %v", group, title, get_commit_location(node)))
+}
+
+func print_schedule_preview(all bool) error {
+
+	course := get_current_node("course")
+	if course == nil {
+		return fmt.Errorf("unable to find current course")
+	}
+
+	schedule, err := get_course_schedule(course)
+	if err != nil {
+		return err
+	}
+
+	slots, err := get_schedule_slots(schedule, course)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(bold_style.Render(course.get_breadcrumb()))
+
+	today := time.Now().Format(CFG_DATE_FORMAT)
+	shown := 0
+
+	for _, slot := range slots {
+		if !all && (slot.Time.Format(CFG_DATE_FORMAT) < today || shown >= CFG_SCHEDULE_PREVIEW) {
+			continue
+		}
+		shown++
+
+		status := schedule.get_slot_title(slot)
+		if slot.Lecture != nil {
+			status = fmt.Sprintf("%v (done)", slot.Lecture.get_title())
+		}
+
+		fmt.Printf("  %3v  %v  %v\n", slot.Number, slot.Time.Format("Mon "+CFG_DATE_FORMAT+" "+CFG_TIME_FORMAT), status)
+	}
+
+	if shown == 0 {
+		fmt.Println("  no upcoming meetings")
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type StatusLevel struct {
+	Group     string `json:"group"`
+	Title     string `json:"title"`
+	Path      string `json:"path,omitempty"`
+	Lectures  int    `json:"lectures"`
+	Composite string `json:"composite,omitempty"`
+}
+
+type StatusLecture struct {
+	Title      string    `json:"title"`
+	Breadcrumb string    `json:"breadcrumb"`
+	Path       string    `json:"path"`
+	Modified   time.Time `json:"modified"`
+}
+
+type StatusBuild struct {
+	Title  string    `json:"title"`
+	Path   string    `json:"path"`
+	Time   time.Time `json:"time"`
+	Errors int       `json:"errors"`
+}
+
+// StatusReport summarises the current context for the status command.
+type StatusReport struct {
+	Current      []StatusLevel  `json:"current"`
+	LastModified *StatusLecture `json:"last_modified"`
+	LastBuild    *StatusBuild   `json:"last_build"`
+	Warnings     []string       `json:"warnings"`
+}
+
+func count_lectures(node *Node) int {
+	if node.get_depth() == len(CFG_DEPTH_GROUP)-1 {
+		return 1
+	}
+	count := 0
+	for _, child := range node.get_children() {
+		count += count_lectures(child)
+	}
+	return count
+}
+
+func walk_nodes(node *Node, fn func(*Node)) {
+	fn(node)
+	for _, child := range node.get_children() {
+		walk_nodes(child, fn)
+	}
+}
+
+func get_status_report() *StatusReport {
+
+	report := &StatusReport{Current: []StatusLevel{}, Warnings: []string{}}
+
+	add_warning := func(format string, args ...interface{}) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	var scope *Node
+
+	// validate_currents reports the first group whose current is unset or
+	// does not exist; the chain above it is still shown.
+	invalid := ""
+	if semester := get_current_node(CFG_DEPTH_GROUP[0]); semester == nil {
+		invalid = CFG_DEPTH_GROUP[0]
+	} else if ok, group := validate_currents(semester); !ok {
+		invalid = group
+	}
+
+	for depth := 0; depth < len(CFG_DEPTH_GROUP); depth++ {
+		group := CFG_DEPTH_GROUP[depth]
+
+		if group == invalid {
+			title, _ := get_config_value(CFG_CURRENT_NODE_PREFIX + group)
+			if title != "" {
+				add_warning("current %v '%v' does not exist", group, title)
+			} else {
+				add_warning("no current %v", group)
+			}
+			report.Current = append(report.Current, StatusLevel{Group: group, Title: title})
+			break
+		}
+
+		node := get_current_node(group)
+		if node == nil {
+			break
+		}
+
+		level := StatusLevel{
+			Group:    group,
+			Title:    node.get_title(),
+			Path:     node.get_path(),
+			Lectures: count_lectures(node),
+		}
+		if filepath.Ext(node.get_path()) != CFG_NOTE_FILETYPE {
+			level.Composite, _ = get_composite_file(node.get_path())
+		}
+
+		report.Current = append(report.Current, level)
+
+		if group == "course" || scope == nil {
+			scope = node
+		}
+	}
+
+	if scope == nil {
+		return report
+	}
+
+	walk_nodes(scope, func(node *Node) {
+
+		if filepath.Ext(node.get_path()) == CFG_NOTE_FILETYPE {
+			info, err := os.Stat(node.get_path())
+			if err != nil {
+				return
+			}
+			if report.LastModified == nil || info.ModTime().After(report.LastModified.Modified) {
+				report.LastModified = &StatusLecture{
+					Title:      node.get_title(),
+					Breadcrumb: node.get_breadcrumb(),
+					Path:       node.get_path(),
+					Modified:   info.ModTime(),
+				}
+			}
+			return
+		}
+
+		// Semesters only group courses and never have a composite file.
+		if node.get_depth() == 0 {
+			return
+		}
+
+		if composite, err := get_composite_file(node.get_path()); err != nil || composite == "" {
+			add_warning("%v has no composite file", node.get_breadcrumb())
+		}
+
+		if build := get_last_build(node); build != nil {
+			if report.LastBuild == nil || build.Time.After(report.LastBuild.Time) {
+				report.LastBuild = build
+			}
+		}
+	})
+
+	if NoteIndex != nil {
+		if issues := lint_node(scope); len(issues) > 0 {
+			add_warning("%v lint issue(s) in %v", len(issues), scope.get_breadcrumb())
+		}
+	}
+
+	return report
+}
+
+// get_last_build reads the result record_build_result stored in the node's
+// info.json, if any.
+func get_last_build(node *Node) *StatusBuild {
+
+	info_path := filepath.Join(node.get_path(), CFG_INFO_FILENAME+".json")
+
+	value, err := read_json_value(info_path, "last-build")
+	if err != nil || value == "" {
+		return nil
+	}
+
+	built, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+
+	build := &StatusBuild{Title: node.get_title(), Path: node.get_path(), Time: built}
+
+	if errors, err := read_json_value(info_path, "last-build-errors"); err == nil {
+		build.Errors, _ = strconv.Atoi(errors)
+	}
+
+	return build
+}
+
+func print_status(report *StatusReport, as_json bool) error {
+
+	if as_json {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	var lines []string
+
+	lines = append(lines, bold_style.Render("Current"))
+	for _, level := range report.Current {
+		if level.Path == "" {
+			lines = append(lines, fmt.Sprintf("  %-9v %v (missing)", level.Group, level.Title))
+			continue
+		}
+		counts := ""
+		if level.Group != CFG_DEPTH_GROUP[len(CFG_DEPTH_GROUP)-1] {
+			counts = fmt.Sprintf(" [%v lectures]", level.Lectures)
+		}
+		lines = append(lines, fmt.Sprintf("  %-9v %v%v", level.Group, level.Title, counts))
+		lines = append(lines, fmt.Sprintf("  %-9v %v", "", level.Path))
+	}
+
+	lines = append(lines, "", bold_style.Render("Last modified"))
+	if report.LastModified != nil {
+		lines = append(lines, fmt.Sprintf("  %v (%v)",
+			report.LastModified.Breadcrumb,
+			report.LastModified.Modified.Format(CFG_DATE_FORMAT+" "+CFG_TIME_FORMAT)))
+	} else {
+		lines = append(lines, "  no lectures")
+	}
+
+	lines = append(lines, "", bold_style.Render("Last build"))
+	if build := report.LastBuild; build != nil {
+		status := "ok"
+		if build.Errors > 0 {
+			status = fmt.Sprintf("%v error(s)", build.Errors)
+		}
+		lines = append(lines, fmt.Sprintf("  %v: %v (%v)", build.Title, status, build.Time.Format(CFG_DATE_FORMAT+" "+CFG_TIME_FORMAT)))
+	} else {
+		lines = append(lines, "  never built")
+	}
+
+	if len(report.Warnings) > 0 {
+		lines = append(lines, "", bold_style.Render("Warnings"))
+		for _, warning := range report.Warnings {
+			lines = append(lines, "  ! "+warning)
+		}
+	}
+
+	fmt.Println(tree_style.Render(strings.Join(lines, "\n")))
+
+	return nil
+}
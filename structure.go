@@ -0,0 +1,341 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Structure templates map names to entries. Version 1 templates only allow a
+// source path (string) or a nested directory (object). Version 2 templates,
+// marked with "$version": 2, describe every entry with an object:
+//
+//	"preamble.tex": {"source": "files/course/preamble.tex", "mode": "0644"}
+//	"notes.md":     {"content": "# %%title%%\n", "when": "!exam"}
+//	"shared.bib":   {"symlink": "../../shared.bib", "optional": true}
+//	"figures":      {"type": "dir", "children": {...}}
+//
+// The top level may also hold "$substitute" include/exclude globs and
+// "$post_create" commands, which run in the node directory once it exists.
+
+const (
+	STRUCTURE_FILE    = "file"
+	STRUCTURE_DIR     = "dir"
+	STRUCTURE_SYMLINK = "symlink"
+)
+
+type Structure struct {
+	Version    int
+	Include    []string
+	Exclude    []string
+	PostCreate []string
+	Entries    []*StructureEntry
+}
+
+type StructureEntry struct {
+	Name       string
+	JSONPath   string
+	Type       string
+	Source     string
+	Content    *string
+	Symlink    string
+	Mode       os.FileMode
+	Optional   bool
+	When       string
+	PostCreate []string
+	Children   []*StructureEntry
+}
+
+var structure_entry_fields = []string{
+	"type", "source", "content", "symlink", "mode", "optional", "when", "post_create", "children",
+}
+
+// parse_structure turns a decoded structure template into entries, returning
+// every problem found, each prefixed with the JSON path it occurred at.
+func parse_structure(template map[string]interface{}) (*Structure, error) {
+
+	structure := &Structure{Version: 1}
+
+	var problems []string
+
+	if version, ok := template[CFG_VERSION_KEY]; ok {
+		number, ok := version.(float64)
+		if !ok || number != float64(int(number)) || number < 1 || int(number) > CFG_STRUCTURE_VERSION {
+			return nil, fmt.Errorf("$[%q]: unsupported version %v (supported: 1-%v)", CFG_VERSION_KEY, version, CFG_STRUCTURE_VERSION)
+		} else {
+			structure.Version = int(number)
+		}
+	}
+
+	if rules, ok := template[CFG_SUBSTITUTE_KEY]; ok {
+		if rules, ok := rules.(map[string]interface{}); ok {
+			structure.Include = get_string_list(rules["include"])
+			structure.Exclude = get_string_list(rules["exclude"])
+		} else {
+			problems = append(problems, fmt.Sprintf("$[%q]: expected an object", CFG_SUBSTITUTE_KEY))
+		}
+	}
+
+	if commands, ok := template[CFG_POST_CREATE_KEY]; ok {
+		var err error
+		structure.PostCreate, err = parse_commands(commands)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("$[%q]: %v", CFG_POST_CREATE_KEY, err))
+		}
+	}
+
+	structure.Entries = parse_structure_entries(template, "$", structure.Version, &problems)
+
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "\n"))
+	}
+
+	return structure, nil
+}
+
+func parse_structure_entries(template map[string]interface{}, json_path string, version int, problems *[]string) []*StructureEntry {
+
+	var names []string
+	for name := range template {
+		if !strings.HasPrefix(name, CFG_TEMPLATE_META_PREFIX) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var entries []*StructureEntry
+
+	for _, name := range names {
+		entry_path := fmt.Sprintf("%v[%q]", json_path, name)
+		entry := &StructureEntry{Name: name, JSONPath: entry_path}
+
+		switch v := template[name].(type) {
+		case string:
+			entry.Type = STRUCTURE_FILE
+			entry.Source = v
+
+		case map[string]interface{}:
+			if version < 2 {
+				entry.Type = STRUCTURE_DIR
+				entry.Children = parse_structure_entries(v, entry_path, version, problems)
+			} else {
+				parse_structure_object(entry, v, version, problems)
+			}
+
+		default:
+			*problems = append(*problems, fmt.Sprintf("%v: unexpected value type", entry_path))
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func parse_structure_object(entry *StructureEntry, object map[string]interface{}, version int, problems *[]string) {
+
+	report := func(field, format string, args ...interface{}) {
+		*problems = append(*problems, fmt.Sprintf("%v.%v: %v", entry.JSONPath, field, fmt.Sprintf(format, args...)))
+	}
+
+	for field := range object {
+		if !slices.Contains(structure_entry_fields, field) {
+			report(field, "unknown field")
+		}
+	}
+
+	get_string := func(field string) string {
+		value, ok := object[field]
+		if !ok {
+			return ""
+		}
+		if str, ok := value.(string); ok {
+			return str
+		}
+		report(field, "expected a string")
+		return ""
+	}
+
+	entry.Source = get_string("source")
+	entry.Symlink = get_string("symlink")
+	entry.When = get_string("when")
+
+	if content, ok := object["content"]; ok {
+		if str, ok := content.(string); ok {
+			entry.Content = &str
+		} else {
+			report("content", "expected a string")
+		}
+	}
+
+	if optional, ok := object["optional"]; ok {
+		if value, ok := optional.(bool); ok {
+			entry.Optional = value
+		} else {
+			report("optional", "expected true or false")
+		}
+	}
+
+	if mode, ok := object["mode"]; ok {
+		parsed, err := strconv.ParseUint(strings.TrimSuffix(fmt.Sprint(mode), ".0"), 8, 32)
+		if err != nil || parsed > 0777 {
+			report("mode", "invalid permissions %v", mode)
+		} else {
+			entry.Mode = os.FileMode(parsed)
+		}
+	}
+
+	if commands, ok := object["post_create"]; ok {
+		var err error
+		entry.PostCreate, err = parse_commands(commands)
+		if err != nil {
+			report("post_create", "%v", err)
+		}
+	}
+
+	if err := validate_condition(entry.When); err != nil {
+		report("when", "%v", err)
+	}
+
+	entry.Type = get_string("type")
+
+	if entry.Type == "" {
+		switch {
+		case entry.Symlink != "":
+			entry.Type = STRUCTURE_SYMLINK
+		case entry.Source != "" || entry.Content != nil:
+			entry.Type = STRUCTURE_FILE
+		default:
+			entry.Type = STRUCTURE_DIR
+		}
+	}
+
+	switch entry.Type {
+	case STRUCTURE_FILE:
+		if (entry.Source == "") == (entry.Content == nil) {
+			report("source", "a file needs exactly one of source or content")
+		}
+	case STRUCTURE_SYMLINK:
+		if entry.Symlink == "" {
+			report("symlink", "missing link target")
+		}
+	case STRUCTURE_DIR:
+		if children, ok := object["children"]; ok {
+			if children, ok := children.(map[string]interface{}); ok {
+				entry.Children = parse_structure_entries(children, entry.JSONPath+".children", version, problems)
+			} else {
+				report("children", "expected an object")
+			}
+		}
+	default:
+		report("type", "unknown type '%v'", entry.Type)
+	}
+}
+
+func parse_commands(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		commands := get_string_list(v)
+		if len(commands) != len(v) {
+			return nil, fmt.Errorf("expected a list of commands")
+		}
+		return commands, nil
+	}
+	return nil, fmt.Errorf("expected a command or a list of commands")
+}
+
+// validate_condition checks the syntax of a when condition: "name",
+// "!name", "name == value" or "name != value".
+func validate_condition(condition string) error {
+	for _, operator := range []string{"==", "!="} {
+		if name, _, found := strings.Cut(condition, operator); found {
+			if strings.TrimSpace(name) == "" {
+				return fmt.Errorf("missing variable in condition '%v'", condition)
+			}
+			return nil
+		}
+	}
+	if strings.ContainsAny(condition, " =") {
+		return fmt.Errorf("invalid condition '%v'", condition)
+	}
+	return nil
+}
+
+// evaluate_condition decides a when condition against the template
+// variables. A bare variable name is true if the variable is non-empty and
+// not "false".
+func evaluate_condition(condition string, variables map[string]string) bool {
+
+	condition = strings.TrimSpace(condition)
+	if condition == "" {
+		return true
+	}
+
+	if name, value, found := strings.Cut(condition, "!="); found {
+		return variables[strings.TrimSpace(name)] != strings.TrimSpace(value)
+	}
+	if name, value, found := strings.Cut(condition, "=="); found {
+		return variables[strings.TrimSpace(name)] == strings.TrimSpace(value)
+	}
+
+	if name, negated := strings.CutPrefix(condition, "!"); negated {
+		return !evaluate_condition(name, variables)
+	}
+
+	value := variables[condition]
+	return value != "" && value != "false"
+}
+
+// resolve_structure_sources applies resolve_template_source to every source
+// in the structure.
+func resolve_structure_sources(entries []*StructureEntry, dirs []string) {
+	for _, entry := range entries {
+		if entry.Source != "" {
+			entry.Source = resolve_template_source(entry.Source, dirs, dirs[len(dirs)-1])
+		}
+		resolve_structure_sources(entry.Children, dirs)
+	}
+}
+
+// validate_structure_sources reports sources that do not exist, unless the
+// entry is optional.
+func validate_structure_sources(entries []*StructureEntry) []string {
+	var problems []string
+
+	for _, entry := range entries {
+		if entry.Source != "" && !entry.Optional {
+			if _, err := os.Stat(entry.Source); err != nil {
+				problems = append(problems, fmt.Sprintf("%v.source: '%v' does not exist", entry.JSONPath, entry.Source))
+			}
+		}
+		problems = append(problems, validate_structure_sources(entry.Children)...)
+	}
+
+	return problems
+}
+
+// get_post_create_commands collects the top-level and per-entry post_create
+// commands of every entry that was created, in template order.
+func (s *Structure) get_post_create_commands(variables map[string]string) []string {
+	commands := append([]string{}, s.PostCreate...)
+	return append(commands, collect_post_create(s.Entries, variables)...)
+}
+
+func collect_post_create(entries []*StructureEntry, variables map[string]string) []string {
+	var commands []string
+	for _, entry := range entries {
+		if !evaluate_condition(entry.When, variables) {
+			continue
+		}
+		commands = append(commands, entry.PostCreate...)
+		commands = append(commands, collect_post_create(entry.Children, variables)...)
+	}
+	return commands
+}
@@ -7,5 +7,6 @@ import (
 
 var tree_style = lg.NewStyle().Border(lg.NormalBorder()).Padding(0, 1, 0, 1)
 var bold_style = lg.NewStyle().Bold(true).Foreground(lg.Color("#ffffff"))
+var selected_style = lg.NewStyle().Reverse(true)
 
 var form_theme = huh.ThemeBase()
@@ -5,27 +5,224 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
 )
 
-func apply_template(template_path string, node *Node) error {
+// apply_template creates the node from its template, substitutes the
+// template variables and runs any post_create commands. It returns the paths
+// of all files it created.
+func apply_template(template_path string, node *Node) ([]string, error) {
 	switch filepath.Ext(template_path) {
 	case ".json":
+		template, err := parse_template(template_path)
+		if err != nil {
+			return nil, err
+		}
+		structure, err := parse_structure(template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %s:\n%w", template_path, err)
+		}
+		dirs, err := get_template_dirs(node.get_parent())
+		if err != nil {
+			return nil, err
+		}
+		resolve_structure_sources(structure.Entries, dirs)
 		if err := os.MkdirAll(node.get_path(), os.ModePerm); err != nil {
-			return err
+			return nil, err
 		}
-		template, err := parse_template(template_path)
+		variables := get_template_variables(node)
+		created, err := create_file_structure(structure.Entries, node.get_path(), variables)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if err := create_file_structure(template, node.get_path()); err != nil {
-			return err
+		if err := write_info_json_values(node); err != nil {
+			return nil, err
+		}
+		files, err := filter_substitution_files(structure, node.get_path(), created)
+		if err != nil {
+			return nil, err
+		}
+		if err := populate_note_fields(node, files); err != nil {
+			return nil, err
 		}
-		return write_info_json_values(node)
+		commands := structure.get_post_create_commands(variables)
+		if err := run_commands(commands, node.get_path(), get_hook_environment(node, nil)); err != nil {
+			return nil, fmt.Errorf("post_create %w", err)
+		}
+		return created, nil
 	case ".tex":
-		return copy_file(template_path, node.get_path())
+		if err := copy_file(template_path, node.get_path()); err != nil {
+			return nil, err
+		}
+		return []string{node.get_path()}, populate_note_fields(node, []string{node.get_path()})
 	}
-	return nil
+	return nil, nil
+}
+
+// get_template_dirs lists the directories templates are looked up in for a
+// new child of parent: the override directory of parent and each of its
+// ancestors, nearest first, followed by the global CFG_TEMPLATE_DIR.
+func get_template_dirs(parent *Node) ([]string, error) {
+
+	root_dir, err := get_config_value(CFG_ROOT_FIELD)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+
+	for ancestor := parent; ancestor != nil; ancestor = ancestor.get_parent() {
+		override_dir := filepath.Join(ancestor.get_path(), CFG_OVERRIDE_TEMPLATE_DIR)
+		if info, err := os.Stat(override_dir); err == nil && info.IsDir() {
+			dirs = append(dirs, override_dir)
+		}
+	}
+
+	return append(dirs, filepath.Join(root_dir, CFG_TEMPLATE_DIR)), nil
+}
+
+// find_template returns the first structure or file template named name in
+// the template directories of parent.
+func find_template(parent *Node, name string) (string, error) {
+
+	dirs, err := get_template_dirs(parent)
+	if err != nil {
+		return "", err
+	}
+
+	for _, dir := range dirs {
+		if path, err := find_path("file", dir, name); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("no template found for '%v'", name)
+}
+
+type NamedTemplate struct {
+	Name string
+	Path string
+}
+
+// list_templates returns the templates available for group: the default
+// template (named after the group) followed by every template in a
+// structure/<group> directory. Nearer template directories shadow templates
+// of the same name further out.
+func list_templates(parent *Node, group string) ([]NamedTemplate, error) {
+
+	var templates []NamedTemplate
+
+	if path, err := find_template(parent, group); err == nil {
+		templates = append(templates, NamedTemplate{group, path})
+	}
+
+	dirs, err := get_template_dirs(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{group: true}
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(filepath.Join(dir, "structure", group))
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			templates = append(templates, NamedTemplate{name, filepath.Join(dir, "structure", group, entry.Name())})
+		}
+	}
+
+	if len(templates) < 1 {
+		return nil, fmt.Errorf("no template found for '%v'", group)
+	}
+
+	return templates, nil
+}
+
+// find_named_template returns the template called name for group, or the
+// default template if name is empty.
+func find_named_template(parent *Node, group, name string) (string, error) {
+
+	if name == "" {
+		return find_template(parent, group)
+	}
+
+	templates, err := list_templates(parent, group)
+	if err != nil {
+		return "", err
+	}
+
+	for _, template := range templates {
+		if template.Name == name {
+			return template.Path, nil
+		}
+	}
+
+	return "", fmt.Errorf("no %v template named '%v'", group, name)
+}
+
+// get_template_preview returns the first CFG_PREVIEW_LINES lines of a
+// template file.
+func get_template_preview(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err.Error()
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > CFG_PREVIEW_LINES {
+		lines = append(lines[:CFG_PREVIEW_LINES], "...")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// resolve_template_source returns the file a structure template source
+// refers to, letting overrides win: a source is replaced by a file at the
+// same relative path, or with the same name, in a nearer template directory.
+// Relative sources are resolved against the template directories.
+func resolve_template_source(source string, dirs []string, global_dir string) string {
+
+	rel := source
+	if filepath.IsAbs(source) {
+		rel = ""
+		if r, err := filepath.Rel(global_dir, source); err == nil && !strings.HasPrefix(r, "..") {
+			rel = r
+		}
+	}
+
+	for _, dir := range dirs {
+		if rel != "" {
+			if _, err := os.Stat(filepath.Join(dir, rel)); err == nil {
+				return filepath.Join(dir, rel)
+			}
+		}
+
+		if dir == global_dir {
+			continue
+		}
+
+		if path, err := find_file_by_name(dir, filepath.Base(source)); err == nil {
+			return path
+		}
+	}
+
+	return source
 }
 
 func parse_template(path string) (map[string]interface{}, error) {
@@ -40,6 +237,73 @@ func parse_template(path string) (map[string]interface{}, error) {
 	return template, nil
 }
 
+// filter_substitution_files applies the include/exclude globs of the
+// template's "$substitute" entry to the created files and drops symlinks and
+// binary files.
+// Globs match either the path relative to root or the file name; without an
+// include list every file is included.
+func filter_substitution_files(structure *Structure, root string, created []string) ([]string, error) {
+
+	include, exclude := structure.Include, structure.Exclude
+
+	var files []string
+
+	for _, path := range created {
+		if info, err := os.Lstat(path); err != nil || info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if len(include) > 0 && !match_any_glob(include, rel) {
+			continue
+		}
+		if match_any_glob(exclude, rel) {
+			continue
+		}
+
+		binary, err := is_binary_file(path)
+		if err != nil {
+			return nil, err
+		}
+		if binary {
+			continue
+		}
+
+		files = append(files, path)
+	}
+
+	return files, nil
+}
+
+func match_any_glob(globs []string, rel string) bool {
+	for _, glob := range globs {
+		if matched, _ := filepath.Match(glob, rel); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(glob, filepath.Base(rel)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func get_string_list(value interface{}) []string {
+	var result []string
+	if list, ok := value.([]interface{}); ok {
+		for _, item := range list {
+			if str, ok := item.(string); ok {
+				result = append(result, str)
+			}
+		}
+	}
+	return result
+}
+
 func write_info_json_values(node *Node) error {
 	infoPath := filepath.Join(node.get_path(), "info.json")
 	if err := write_json_value(infoPath, "title", node.get_title()); err != nil {
@@ -51,68 +315,195 @@ func write_info_json_values(node *Node) error {
 	return write_json_value(infoPath, "id", node.get_id())
 }
 
-func populate_note_fields(node *Node) error {
+// get_template_variables collects every value a template can reference as
+// %%name%%. Later sources take precedence: config defaults, user variables
+// from the config, user variables from the info.json of every ancestor
+// (nearest last), and finally the node's own fields (a lecture's date in the
+// configured format) and ancestor titles.
+func get_template_variables(node *Node) map[string]string {
 
-	fields := get_struct_field_names(*node)
+	variables := map[string]string{}
 
-	placeholders := map[string]interface{}{}
+	date_format := CFG_DATE_FORMAT
+	if format, err := get_config_value(CFG_DATE_FORMAT_FIELD); err == nil && format != "" {
+		date_format = format
+	}
+
+	time_format := CFG_TIME_FORMAT
+	if format, err := get_config_value(CFG_TIME_FORMAT_FIELD); err == nil && format != "" {
+		time_format = format
+	}
 
-	for _, field := range fields {
-		value := node.get_field_value_by_name(field)
+	now := time.Now()
+	variables["created"] = now.Format(date_format)
+	variables["created-time"] = now.Format(time_format)
 
-		if str, ok := value.(string); ok {
-			tex_field := CFG_REPLACE_MARKER + strings.ToLower(field) + CFG_REPLACE_MARKER
-			placeholders[tex_field] = str
-		} else {
-			if node, ok := value.(*Node); ok {
-				if node != nil {
-					tex_field := CFG_REPLACE_MARKER + strings.ToLower(node.get_group()) + CFG_REPLACE_MARKER
-					placeholders[tex_field] = node.get_title()
-				}
+	for _, field := range CFG_CONFIG_VARIABLES {
+		variables[field], _ = get_config_value(field)
+	}
+
+	if config_variables, err := get_config_field(CFG_VARIABLES_FIELD); err == nil {
+		add_user_variables(variables, config_variables)
+	}
+
+	var ancestors []*Node
+	for current := node; current != nil; current = current.get_parent() {
+		ancestors = append([]*Node{current}, ancestors...)
+	}
+
+	for _, ancestor := range ancestors {
+		if filepath.Ext(ancestor.get_path()) == CFG_NOTE_FILETYPE {
+			continue
+		}
+		info_path := filepath.Join(ancestor.get_path(), CFG_INFO_FILENAME+".json")
+		if info_variables, err := read_json_field(info_path, CFG_VARIABLES_FIELD); err == nil {
+			add_user_variables(variables, info_variables)
+		}
+	}
+
+	for _, field := range get_struct_field_names(*node) {
+		if str, ok := node.get_field_value_by_name(field).(string); ok {
+			variables[strings.ToLower(field)] = str
+		}
+	}
+
+	if date, err := time.Parse(CFG_DATE_FORMAT, node.get_date()); err == nil {
+		variables["date"] = date.Format(date_format)
+	}
+
+	for _, ancestor := range ancestors {
+		variables[ancestor.get_group()] = ancestor.get_title()
+	}
+
+	// Templates are rendered when the node is created, so it is numbered
+	// after all of its existing siblings. Their order on disk is
+	// alphabetical and says nothing about the node's position.
+	if parent := node.get_parent(); parent != nil {
+		number := 1
+		for _, child := range parent.get_children() {
+			if child != node {
+				number++
 			}
 		}
+		variables["number"] = fmt.Sprint(number)
 	}
 
-	if filepath.Ext(node.get_path()) == CFG_NOTE_FILETYPE {
-		data, err := os.ReadFile(node.get_path())
-		if err != nil {
-			return err
+	return variables
+}
+
+func add_user_variables(variables map[string]string, value interface{}) {
+	if user_variables, ok := value.(map[string]interface{}); ok {
+		for name, value := range user_variables {
+			variables[name] = fmt.Sprint(value)
 		}
+	}
+}
+
+// populate_note_fields substitutes the template variables of node into each
+// of the given files.
+func populate_note_fields(node *Node, files []string) error {
 
-		file_content := string(data)
+	variables := get_template_variables(node)
 
-		for placeholder, value := range placeholders {
-			file_content = strings.ReplaceAll(file_content, placeholder, value.(string))
+	for _, file := range files {
+		if err := render_template_file(file, node, variables); err != nil {
+			return err
 		}
+	}
 
-		os.WriteFile(node.get_path(), []byte(file_content), os.ModePerm)
+	return nil
+}
 
-		return nil
+// render_template_file executes the text/template actions of the file at path,
+// if it uses any, and then replaces every %%name%% marker.
+func render_template_file(path string, node *Node, variables map[string]string) error {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
 	}
 
-	files, err := os.ReadDir(node.get_path())
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
-	for _, file := range files {
-		if filepath.Ext(file.Name()) == CFG_NOTE_FILETYPE {
-			data, err := os.ReadFile(filepath.Join(node.get_path(), file.Name()))
-			if err != nil {
-				return err
-			}
+	file_content := string(data)
+
+	// The template runs first, so that delimiters in substituted values
+	// (e.g. a title) are not parsed as actions.
+	if strings.Contains(file_content, CFG_TEMPLATE_LEFT_DELIM) {
+		file_content, err = execute_template(path, file_content, node, variables)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, value := range variables {
+		file_content = strings.ReplaceAll(file_content, CFG_REPLACE_MARKER+name+CFG_REPLACE_MARKER, value)
+	}
+
+	return os.WriteFile(path, []byte(file_content), info.Mode())
+}
+
+// execute_template runs content as a Go text/template. Every template
+// variable is available as .name (or through var for names like
+// created-time) and the node itself as .Node.
+func execute_template(name, content string, node *Node, variables map[string]string) (string, error) {
 
-			file_content := string(data)
+	node_dir := node.get_path()
+	if filepath.Ext(node_dir) == CFG_NOTE_FILETYPE {
+		node_dir = filepath.Dir(node_dir)
+	}
 
-			for placeholder, value := range placeholders {
-				file_content = strings.ReplaceAll(file_content, placeholder, value.(string))
+	functions := template.FuncMap{
+		"var": func(name string) string {
+			return variables[name]
+		},
+		"now": time.Now,
+		"date": func(layout string) string {
+			return time.Now().Format(layout)
+		},
+		"ancestor": func(group string) *Node {
+			return node.get_ancestor(group)
+		},
+		"children": func(groups ...string) []*Node {
+			if len(groups) > 0 {
+				if ancestor := node.get_ancestor(groups[0]); ancestor != nil {
+					return ancestor.get_children()
+				}
+				return nil
 			}
+			return node.get_children()
+		},
+		"exists": func(path string) bool {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(node_dir, path)
+			}
+			_, err := os.Stat(path)
+			return err == nil
+		},
+	}
 
-			os.WriteFile(filepath.Join(node.get_path(), file.Name()), []byte(file_content), os.ModePerm)
-		}
+	tmpl, err := template.New(filepath.Base(name)).
+		Delims(CFG_TEMPLATE_LEFT_DELIM, CFG_TEMPLATE_RIGHT_DELIM).
+		Funcs(functions).
+		Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", name, err)
 	}
 
-	return nil
+	data := map[string]interface{}{"Node": node}
+	for name, value := range variables {
+		data[name] = value
+	}
+
+	var result strings.Builder
+	if err := tmpl.Execute(&result, data); err != nil {
+		return "", fmt.Errorf("failed to execute template %s: %w", name, err)
+	}
+
+	return result.String(), nil
 }
 
 func add_children_to_input_file(node *Node) error {
@@ -136,18 +527,11 @@ func add_children_to_input_file(node *Node) error {
 	placeholder := "% INPUT"
 
 	for _, child := range node.get_children() {
-		// Get the "composite" file for the child (the .tex file we want to \input)
-		childFile, err := get_composite_file(child.get_path())
+		newLine, err := get_input_line(child)
 		if err != nil {
 			return err
 		}
 
-		// Build the new line we want to insert, e.g.
-		//   \input{.../Lec1.tex} % Lec1
-		newLine := fmt.Sprintf("\\input{%s} %% %s",
-			filepath.Join(child.get_path(), filepath.Base(childFile)),
-			child.get_title())
-
 		// 1) Check if the exact line is already present
 		//    If it's there, skip adding it again.
 		if strings.Contains(parentContent, newLine) {
@@ -197,15 +581,71 @@ func add_children_to_input_file(node *Node) error {
 		parentContent = strings.Join(lines, "\n")
 	}
 
+	if order, err := get_config_value(CFG_COMPOSITE_ORDER_FIELD); err == nil && order == CFG_ORDER_CHRONOLOGICAL {
+		parentContent = strings.Join(sort_input_lines(lines, node), "\n")
+	}
+
 	// Finally, write the updated content back to the parent file
 	err = os.WriteFile(parentFile, []byte(parentContent), 0755)
 	if err != nil {
 		return fmt.Errorf("error writing updated composite file %s: %v", parentFile, err)
 	}
 
+	track_change(parentFile)
+
 	return nil
 }
 
+// get_input_line returns the line that includes child in its parent's
+// composite file, e.g.
+//
+//	\input{.../Lec1.tex} % Lec1
+func get_input_line(child *Node) (string, error) {
+	// Get the "composite" file for the child (the .tex file we want to \input)
+	childFile, err := get_composite_file(child.get_path())
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("\\input{%s} %% %s",
+		filepath.Join(child.get_path(), filepath.Base(childFile)),
+		child.get_title()), nil
+}
+
+// sort_input_lines orders the \input lines of node's children by date,
+// keeping undated children and any other inputs after them in their
+// original order.
+func sort_input_lines(lines []string, node *Node) []string {
+
+	dates := map[string]string{}
+	for _, child := range node.get_children() {
+		if line, err := get_input_line(child); err == nil {
+			dates[line] = get_node_date(child)
+		}
+	}
+
+	var indices []int
+	var inputs []string
+
+	for i, line := range lines {
+		if _, ok := dates[strings.TrimSpace(line)]; ok {
+			indices = append(indices, i)
+			inputs = append(inputs, line)
+		}
+	}
+
+	sort.SliceStable(inputs, func(i, j int) bool {
+		a, b := dates[strings.TrimSpace(inputs[i])], dates[strings.TrimSpace(inputs[j])]
+		return a != "" && (b == "" || a < b)
+	})
+
+	for i, index := range indices {
+		lines[index] = inputs[i]
+	}
+
+	return lines
+}
+
 func remove_from_parent_input_file(node *Node) error {
 	parent := node.get_parent()
 	if parent == nil {
@@ -230,11 +670,14 @@ func remove_from_parent_input_file(node *Node) error {
 	lines := strings.Split(content, "\n")
 	filteredLines := make([]string, 0, len(lines)) // Create a new slice for filtered lines
 
-	lineToRemove := fmt.Sprintf("%% %v", node.get_title()) // The line to match
+	// Match the whole trailing comment, so that removing "Lec1" keeps the
+	// lines of "Lec10", "Lec11", ...
+	lineToRemove := fmt.Sprintf("} %% %v", node.get_title())
 
 	lineFound := false
 	for _, line := range lines {
-		if strings.Contains(line, lineToRemove) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, `\input{`) && strings.HasSuffix(trimmed, lineToRemove) {
 			lineFound = true
 			continue // Skip this line, effectively removing it
 		}
@@ -261,6 +704,69 @@ func remove_from_parent_input_file(node *Node) error {
 		return fmt.Errorf("failed to write updated content to '%v': %w", composite_file, err)
 	}
 
+	track_change(composite_file)
+
+	return nil
+}
+
+// relink_input_files rewrites the \input lines of every composite file below
+// node that still point into old_path, after node was moved there from it.
+func relink_input_files(node *Node, old_path string) error {
+	if filepath.Ext(node.get_path()) == CFG_NOTE_FILETYPE {
+		return nil
+	}
+
+	return walk_note_files(node.get_path(), func(path string) error {
+		return relink_input_file(path, old_path, node.get_path(), "", "")
+	})
+}
+
+// relink_input_file points \input lines of file into new_path instead of
+// old_path. If old_title is given, the "% title" comment of the line
+// inputting old_path itself is renamed too.
+func relink_input_file(file, old_path, new_path, old_title, new_title string) error {
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	changed := false
+
+	for i, line := range lines {
+		prefix, rest, found := strings.Cut(line, `\input{`+old_path)
+		if !found || !(strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, "}")) {
+			continue
+		}
+
+		line = prefix + `\input{` + new_path + rest
+
+		if old_title != "" {
+			if head, found := strings.CutSuffix(line, "% "+old_title); found {
+				line = head + "% " + new_title
+			}
+		}
+
+		lines[i] = line
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), info.Mode()); err != nil {
+		return err
+	}
+
+	track_change(file)
+
 	return nil
 }
 
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// get_template_parent returns the node new members of group are created
+// under, so template commands see the same overrides as `new` would.
+func get_template_parent(group string) *Node {
+	if CFG_GROUP_DEPTH[group] < 1 {
+		return nil
+	}
+	return get_current_node(CFG_DEPTH_GROUP[CFG_GROUP_DEPTH[group]-1])
+}
+
+func print_template_list() error {
+
+	root_dir, err := get_config_value(CFG_ROOT_FIELD)
+	if err != nil {
+		return err
+	}
+
+	for depth := 0; depth < len(CFG_DEPTH_GROUP); depth++ {
+		group := CFG_DEPTH_GROUP[depth]
+		parent := get_template_parent(group)
+
+		fmt.Println(bold_style.Render(group))
+
+		templates, err := list_templates(parent, group)
+		if err != nil {
+			fmt.Printf("  %v\n", err)
+		}
+
+		listed := map[string]bool{}
+
+		for _, template := range templates {
+			fmt.Printf("  %-20v %v\n", template.Name, get_display_path(root_dir, template.Path))
+			listed[template.Path] = true
+		}
+
+		dirs, err := get_template_dirs(parent)
+		if err != nil {
+			return err
+		}
+
+		for _, dir := range dirs {
+			filepath.WalkDir(filepath.Join(dir, "files", group), func(path string, entry os.DirEntry, err error) error {
+				if err != nil || entry.IsDir() || listed[path] {
+					return nil
+				}
+				fmt.Printf("  %-20v %v\n", "file", get_display_path(root_dir, path))
+				return nil
+			})
+		}
+	}
+
+	return nil
+}
+
+func get_display_path(root_dir, path string) string {
+	if rel, err := filepath.Rel(root_dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func get_template_argument(positional []string) (string, string, error) {
+	if len(positional) < 1 || !valid_node_group(get_alias_group(positional[0])) {
+		return "", "", fmt.Errorf("expected a group")
+	}
+
+	group := get_alias_group(positional[0])
+	name := ""
+
+	if len(positional) > 1 {
+		name = positional[1]
+	}
+
+	path, err := find_named_template(get_template_parent(group), group, name)
+	return group, path, err
+}
+
+// create_named_template adds structure/<group>/<name> to the global template
+// directory, either as a copy of the group's default template or, if from is
+// given, scaffolded from that node's files.
+func create_named_template(group, name string, from *Node) (string, error) {
+
+	root_dir, err := get_config_value(CFG_ROOT_FIELD)
+	if err != nil {
+		return "", err
+	}
+
+	template_dir := filepath.Join(root_dir, CFG_TEMPLATE_DIR)
+
+	if from != nil {
+		if group != "" && group != from.get_group() {
+			return "", fmt.Errorf("'%v' is a %v, not a %v", from.get_title(), from.get_group(), group)
+		}
+		group = from.get_group()
+	}
+
+	if existing, err := list_templates(get_template_parent(group), group); err == nil {
+		for _, template := range existing {
+			if template.Name == name {
+				return "", fmt.Errorf("%v template '%v' already exists", group, name)
+			}
+		}
+	}
+
+	structure_dir := filepath.Join(template_dir, "structure", group)
+	if err := os.MkdirAll(structure_dir, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	if from == nil {
+		source, err := find_template(get_template_parent(group), group)
+		if err != nil {
+			return "", err
+		}
+		destination := filepath.Join(structure_dir, name+filepath.Ext(source))
+		return destination, copy_file(source, destination)
+	}
+
+	if filepath.Ext(from.get_path()) == CFG_NOTE_FILETYPE {
+		destination := filepath.Join(structure_dir, name+CFG_NOTE_FILETYPE)
+		return destination, copy_scaffold_file(from.get_path(), destination, from.get_title())
+	}
+
+	files_dir := filepath.Join("files", group, name)
+	destination := filepath.Join(structure_dir, name+".json")
+
+	for _, path := range []string{destination, filepath.Join(template_dir, files_dir)} {
+		if _, err := os.Stat(path); err == nil {
+			return "", fmt.Errorf("file %s already exists", path)
+		}
+	}
+
+	// A failed scaffold must not leave a partial template behind.
+	cleanup := func(err error) (string, error) {
+		os.RemoveAll(filepath.Join(template_dir, files_dir))
+		return "", err
+	}
+
+	structure, err := scaffold_structure(from, from.get_path(), template_dir, files_dir)
+	if err != nil {
+		return cleanup(err)
+	}
+
+	data, err := json.MarshalIndent(structure, "", "  ")
+	if err != nil {
+		return cleanup(err)
+	}
+
+	if err := os.WriteFile(destination, data, 0644); err != nil {
+		return cleanup(err)
+	}
+
+	return destination, nil
+}
+
+// scaffold_structure mirrors the directory dir of node as a structure
+// template, copying its files below template_dir/files_dir. Directories
+// holding children, build output and hidden directories are left empty.
+func scaffold_structure(node *Node, dir, template_dir, files_dir string) (map[string]interface{}, error) {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	structure := map[string]interface{}{}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		if entry.IsDir() {
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			if entry.Name() == CFG_BUILD_DIR || entry.Name() == CFG_DEPTH_GROUP[node.get_depth()+1] {
+				structure[entry.Name()] = map[string]interface{}{}
+				continue
+			}
+
+			nested, err := scaffold_structure(node, path, template_dir, filepath.Join(files_dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			structure[entry.Name()] = nested
+			continue
+		}
+
+		if entry.Name() == CFG_INFO_FILENAME+".json" {
+			structure[entry.Name()] = filepath.Join("files", entry.Name())
+			continue
+		}
+
+		source := filepath.Join(files_dir, entry.Name())
+		if err := os.MkdirAll(filepath.Join(template_dir, files_dir), os.ModePerm); err != nil {
+			return nil, err
+		}
+		if err := copy_scaffold_file(path, filepath.Join(template_dir, source), node.get_title()); err != nil {
+			return nil, err
+		}
+		structure[entry.Name()] = source
+	}
+
+	return structure, nil
+}
+
+// copy_scaffold_file copies a node file into a template, turning {title}
+// arguments back into {%%title%%} placeholders. The \input lines of a
+// composite file point at the node's own children and are dropped.
+func copy_scaffold_file(src, dst, title string) error {
+
+	if err := copy_file(src, dst); err != nil {
+		return err
+	}
+
+	if binary, err := is_binary_file(dst); err != nil || binary {
+		return err
+	}
+
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		return err
+	}
+
+	placeholder := CFG_REPLACE_MARKER + "title" + CFG_REPLACE_MARKER
+	content := strings.ReplaceAll(string(data), "{"+title+"}", "{"+placeholder+"}")
+
+	if strings.Contains(content, "% COMPOSITE") {
+		content = clear_input_section(content)
+	}
+
+	return os.WriteFile(dst, []byte(content), 0644)
+}
+
+// clear_input_section removes the \input lines following the "% INPUT"
+// marker, leaving the marker for add_children_to_input_file.
+func clear_input_section(content string) string {
+
+	var lines []string
+
+	in_section := false
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "% INPUT":
+			in_section = true
+		case in_section && strings.HasPrefix(trimmed, `\input{`):
+			continue
+		case in_section && trimmed != "":
+			in_section = false
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// validate_templates checks that every structure template in the template
+// directories parses, follows the schema and that every file it references
+// exists.
+func validate_templates() ([]string, error) {
+
+	parent := get_current_node(CFG_DEPTH_GROUP[len(CFG_DEPTH_GROUP)-2])
+
+	dirs, err := get_template_dirs(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+
+	for _, dir := range dirs {
+		err := filepath.WalkDir(filepath.Join(dir, "structure"), func(path string, entry os.DirEntry, err error) error {
+			if errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || filepath.Ext(path) != ".json" {
+				return nil
+			}
+
+			template, err := parse_template(path)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%v: %v", path, err))
+				return nil
+			}
+
+			structure, err := parse_structure(template)
+			if err != nil {
+				for _, problem := range strings.Split(err.Error(), "\n") {
+					problems = append(problems, fmt.Sprintf("%v: %v", path, problem))
+				}
+				return nil
+			}
+
+			resolve_structure_sources(structure.Entries, dirs)
+
+			for _, problem := range validate_structure_sources(structure.Entries) {
+				problems = append(problems, fmt.Sprintf("%v: %v", path, problem))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(problems)
+
+	return problems, nil
+}
+
+func handle_template_command(raw []string) error {
+
+	positional, flags := parse_flags(raw, "from")
+
+	if len(positional) < 1 {
+		return fmt.Errorf("usage: template list|show|edit|new|validate")
+	}
+
+	switch positional[0] {
+	case "list", "ls":
+		return print_template_list()
+
+	case "show":
+		_, path, err := get_template_argument(positional[1:])
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Println(bold_style.Render(path))
+		fmt.Print(string(data))
+
+	case "edit":
+		_, path, err := get_template_argument(positional[1:])
+		if err != nil {
+			return err
+		}
+		return open_file(path, 0)
+
+	case "new":
+		var from *Node
+		if flags["from"] != "" {
+			node, err := resolve_node(flags["from"])
+			if err != nil {
+				return err
+			}
+			from = node
+		}
+
+		if len(positional) < 3 && !(from != nil && len(positional) == 2) {
+			return fmt.Errorf("usage: template new <group> <name> | template new <name> --from <node>")
+		}
+
+		group, name := "", positional[len(positional)-1]
+		if len(positional) > 2 {
+			group = get_alias_group(positional[1])
+			if !valid_node_group(group) {
+				return fmt.Errorf("invalid group: %v", positional[1])
+			}
+		}
+
+		path, err := create_named_template(group, name, from)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created template '%v'.\n", path)
+
+	case "validate":
+		problems, err := validate_templates()
+		if err != nil {
+			return err
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%v template problems found", len(problems))
+		}
+		fmt.Println("All templates are valid.")
+
+	default:
+		return fmt.Errorf("unknown template command '%v'", positional[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Assignments are stored in a course's info.json, e.g.
+//
+//	"assignments": [
+//	  {"id": "…", "title": "Sheet 3", "due": "2024-10-15 23:59", "status": "open", "node": "chapter/Limits"}
+//	]
+//
+// The linked node is kept as a path relative to the course, since lectures
+// created before ids were stored in their notes have none.
+//
+// The todo list also shows upcoming course deadlines (see ics.go). Those are
+// fixed dates such as exams rather than work to hand in, so they cannot be
+// completed or removed here.
+
+const (
+	ASSIGNMENT_OPEN = "open"
+	ASSIGNMENT_DONE = "done"
+)
+
+type Assignment struct {
+	ID     string `json:"id,omitempty"`
+	Title  string `json:"title"`
+	Due    string `json:"due"`
+	Status string `json:"status"`
+	Node   string `json:"node,omitempty"`
+}
+
+// TodoItem is an assignment or deadline together with the course it belongs
+// to. Index is its position in the course's assignments.
+type TodoItem struct {
+	Course     *Node
+	Assignment Assignment
+	Index      int
+	Deadline   bool
+	Due        time.Time
+	AllDay     bool
+}
+
+func get_course_assignments(course *Node) ([]Assignment, error) {
+	var assignments []Assignment
+	err := decode_course_field(course, CFG_ASSIGNMENTS_FIELD, &assignments)
+	return assignments, err
+}
+
+func save_course_assignments(course *Node, assignments []Assignment, message string) error {
+
+	if assignments == nil {
+		assignments = []Assignment{}
+	}
+
+	info_path := filepath.Join(course.get_path(), CFG_INFO_FILENAME+".json")
+
+	if err := write_json_field(info_path, CFG_ASSIGNMENTS_FIELD, assignments); err != nil {
+		return err
+	}
+
+	track_change(info_path)
+
+	return commit_changes(message)
+}
+
+// get_node returns the node an assignment is linked to, if it still exists.
+func (a *Assignment) get_node(course *Node) *Node {
+	if a.Node == "" {
+		return nil
+	}
+	return find_node_by_path(filepath.Join(course.get_path(), a.Node))
+}
+
+// get_todo_items collects the assignments and deadlines of every course in
+// semester, sorted by due date. Completed assignments and past deadlines are
+// only included if all is set.
+func get_todo_items(semester *Node, all bool) ([]TodoItem, error) {
+
+	var items []TodoItem
+
+	for _, course := range semester.get_children() {
+		assignments, err := get_course_assignments(course)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, assignment := range assignments {
+			if assignment.Status == ASSIGNMENT_DONE && !all {
+				continue
+			}
+
+			due, all_day, err := parse_due(assignment.Due)
+			if err != nil {
+				return nil, fmt.Errorf("%v assignment '%v': %w", course.get_title(), assignment.Title, err)
+			}
+
+			items = append(items, TodoItem{Course: course, Assignment: assignment, Index: i, Due: due, AllDay: all_day})
+		}
+
+		deadlines, err := get_course_deadlines(course)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, deadline := range deadlines {
+			due, all_day, err := parse_due(deadline.Due)
+			if err != nil {
+				return nil, fmt.Errorf("%v deadline '%v': %w", course.get_title(), deadline.Title, err)
+			}
+
+			item := TodoItem{
+				Course:     course,
+				Assignment: Assignment{ID: deadline.ID, Title: deadline.Title, Due: deadline.Due},
+				Deadline:   true,
+				Due:        due,
+				AllDay:     all_day,
+			}
+			if item.is_overdue() && !all {
+				continue
+			}
+
+			items = append(items, item)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Due.Before(items[j].Due)
+	})
+
+	return items, nil
+}
+
+func (t *TodoItem) String() string {
+
+	due := t.Due.Format("Mon " + CFG_DATE_FORMAT)
+	if !t.AllDay {
+		due += " " + t.Due.Format(CFG_TIME_FORMAT)
+	}
+
+	status := ""
+	switch {
+	case t.Assignment.Status == ASSIGNMENT_DONE:
+		status = "done"
+	case t.Deadline && t.is_overdue():
+		status = "passed"
+	case t.is_overdue():
+		status = "overdue"
+	default:
+		switch days := t.get_days_left(); days {
+		case 0:
+			status = "today"
+		case 1:
+			status = "tomorrow"
+		default:
+			status = fmt.Sprintf("in %v days", days)
+		}
+	}
+
+	title := t.Assignment.Title
+	if t.Deadline {
+		title += " (deadline)"
+	}
+
+	line := fmt.Sprintf("%-20v %-10v %-12v %v", due, status, t.Course.get_title(), title)
+
+	if node := t.Assignment.get_node(t.Course); node != nil {
+		line += "  → " + get_calendar_label(t.Course, node)
+	}
+
+	return line
+}
+
+// get_days_left counts calendar days from today to the due date.
+func (t *TodoItem) get_days_left() int {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	due := time.Date(t.Due.Year(), t.Due.Month(), t.Due.Day(), 0, 0, 0, 0, time.Local)
+	return int(math.Round(due.Sub(today).Hours() / 24))
+}
+
+func (t *TodoItem) is_overdue() bool {
+	if t.AllDay {
+		return t.Due.Format(CFG_DATE_FORMAT) < time.Now().Format(CFG_DATE_FORMAT)
+	}
+	return t.Due.Before(time.Now())
+}
+
+func print_todo_items(items []TodoItem) {
+	if len(items) < 1 {
+		fmt.Println("Nothing to do.")
+		return
+	}
+
+	for _, item := range items {
+		line := item.String()
+		if item.Assignment.Status != ASSIGNMENT_DONE && !item.Deadline && item.is_overdue() {
+			line = bold_style.Render(line)
+		}
+		fmt.Println(line)
+	}
+}
+
+func add_assignment(course *Node, title, due, node_arg string) (*Assignment, error) {
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, fmt.Errorf("an assignment needs a title")
+	}
+
+	if _, _, err := parse_due(due); err != nil {
+		return nil, err
+	}
+
+	assignments, err := get_course_assignments(course)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, assignment := range assignments {
+		if strings.EqualFold(assignment.Title, title) {
+			return nil, fmt.Errorf("assignment '%v' already exists in %v", title, course.get_title())
+		}
+	}
+
+	assignment := Assignment{ID: uuid.NewString(), Title: title, Due: due, Status: ASSIGNMENT_OPEN}
+
+	if node_arg != "" {
+		node, err := resolve_node(node_arg)
+		if err != nil {
+			return nil, err
+		}
+		if !contains_node(course, node) {
+			return nil, fmt.Errorf("'%v' is not part of %v", node.get_breadcrumb(), course.get_title())
+		}
+		assignment.Node, _ = filepath.Rel(course.get_path(), node.get_path())
+	}
+
+	assignments = append(assignments, assignment)
+
+	message := fmt.Sprintf("Add assignment '%v' to %v", title, course.get_title())
+
+	return &assignment, save_course_assignments(course, assignments, message)
+}
+
+// find_todo_item returns the assignment titled title, restricted to course
+// if given. The user picks one if title is empty or several match.
+func find_todo_item(semester *Node, title, course string) (*TodoItem, error) {
+
+	items, err := get_todo_items(semester, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []TodoItem
+
+	for _, item := range items {
+		if item.Deadline {
+			continue
+		}
+		if course != "" && !strings.EqualFold(item.Course.get_title(), course) {
+			continue
+		}
+		if title == "" || strings.EqualFold(item.Assignment.Title, title) {
+			matches = append(matches, item)
+		}
+	}
+
+	if len(matches) == 1 && title != "" {
+		return &matches[0], nil
+	}
+	if len(matches) < 1 && title != "" {
+		return nil, fmt.Errorf("no assignment named '%v'", title)
+	}
+
+	return todo_picker_form(matches)
+}
+
+// is_item reports whether assignment, found at index i of its course, is the
+// one item refers to: by id, or by position for assignments without one.
+func (a *Assignment) is_item(i int, item *TodoItem) bool {
+	if item.Assignment.ID != "" {
+		return a.ID == item.Assignment.ID
+	}
+	return a.ID == "" && i == item.Index && a.Title == item.Assignment.Title
+}
+
+// update_assignment applies change to the stored assignment matching item,
+// or removes it if change returns false.
+func update_assignment(item *TodoItem, message string, change func(*Assignment) bool) error {
+
+	assignments, err := get_course_assignments(item.Course)
+	if err != nil {
+		return err
+	}
+
+	var updated []Assignment
+
+	found := false
+
+	for i, assignment := range assignments {
+		if assignment.is_item(i, item) {
+			found = true
+			if !change(&assignment) {
+				continue
+			}
+		}
+		updated = append(updated, assignment)
+	}
+
+	if !found {
+		return fmt.Errorf("assignment '%v' changed on disk, try again", item.Assignment.Title)
+	}
+
+	return save_course_assignments(item.Course, updated, message)
+}
+
+// join_due_time merges the time following a --due date into its value, so
+// that `--due 2024-10-15 23:59` needs no quotes.
+func join_due_time(raw []string) []string {
+
+	var joined []string
+
+	for i := 0; i < len(raw); i++ {
+		joined = append(joined, raw[i])
+
+		if raw[i] != "--due" || i+2 >= len(raw) {
+			continue
+		}
+		if _, err := time.Parse(CFG_TIME_FORMAT, raw[i+2]); err != nil {
+			continue
+		}
+
+		joined = append(joined, raw[i+1]+" "+raw[i+2])
+		i += 2
+	}
+
+	return joined
+}
+
+func handle_todo_command(raw []string) error {
+
+	positional, flags := parse_flags(join_due_time(raw), "due", "course", "node")
+
+	semester := get_current_node(CFG_DEPTH_GROUP[0])
+	if semester == nil {
+		return fmt.Errorf("unable to find current %v", CFG_DEPTH_GROUP[0])
+	}
+
+	if len(positional) < 1 {
+		items, err := get_todo_items(semester, flags["all"] == "true")
+		if err != nil {
+			return err
+		}
+		print_todo_items(items)
+		return nil
+	}
+
+	title := strings.Join(positional[1:], " ")
+
+	switch positional[0] {
+	case "add":
+		if title == "" || flags["due"] == "" {
+			return fmt.Errorf("usage: todo add <title> --due %v [%v] [--course <title>] [--node <path|id>]", CFG_DATE_FORMAT, CFG_TIME_FORMAT)
+		}
+
+		// A stray time usually belongs to --due, e.g. from --due=<date> <time>.
+		for _, word := range positional[1:] {
+			if _, err := time.Parse(CFG_TIME_FORMAT, word); err == nil {
+				return fmt.Errorf("'%v' looks like a time; pass it as --due %v %v", word, CFG_DATE_FORMAT, CFG_TIME_FORMAT)
+			}
+		}
+
+		course := get_current_node("course")
+		if flags["course"] != "" {
+			course = nil
+			for _, child := range semester.get_children() {
+				if strings.EqualFold(child.get_title(), flags["course"]) {
+					course = child
+				}
+			}
+		}
+		if course == nil {
+			return fmt.Errorf("unable to find course")
+		}
+
+		assignment, err := add_assignment(course, title, flags["due"], flags["node"])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Added '%v' to %v, due %v.\n", assignment.Title, course.get_title(), assignment.Due)
+
+	case "complete", "done":
+		item, err := find_todo_item(semester, title, flags["course"])
+		if err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("Complete assignment '%v' in %v", item.Assignment.Title, item.Course.get_title())
+		err = update_assignment(item, message, func(assignment *Assignment) bool {
+			assignment.Status = ASSIGNMENT_DONE
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Completed '%v'.\n", item.Assignment.Title)
+
+	case "remove", "rm":
+		item, err := find_todo_item(semester, title, flags["course"])
+		if err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("Remove assignment '%v' from %v", item.Assignment.Title, item.Course.get_title())
+		err = update_assignment(item, message, func(assignment *Assignment) bool {
+			return false
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed '%v'.\n", item.Assignment.Title)
+
+	default:
+		return fmt.Errorf("unknown todo command '%v'", positional[0])
+	}
+
+	return nil
+}
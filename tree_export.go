@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	TREE_FORMAT_JSON  = "json"
+	TREE_FORMAT_YAML  = "yaml"
+	TREE_FORMAT_PATHS = "paths"
+	TREE_FORMAT_DOT   = "dot"
+)
+
+// TreeExport is the machine-readable form of a node and its descendants.
+type TreeExport struct {
+	Id        string        `json:"id"`
+	Group     string        `json:"group"`
+	Title     string        `json:"title"`
+	Path      string        `json:"path"`
+	Date      string        `json:"date,omitempty"`
+	Composite string        `json:"composite,omitempty"`
+	Children  []*TreeExport `json:"children"`
+}
+
+// export_tree converts node and its descendants into TreeExports. levels
+// limits the output to that many levels, counting node itself; 0 means no
+// limit.
+func export_tree(node *Node, levels int) *TreeExport {
+
+	export := &TreeExport{
+		Id:       node.get_id(),
+		Group:    node.get_group(),
+		Title:    node.get_title(),
+		Path:     node.get_path(),
+		Date:     node.get_date(),
+		Children: []*TreeExport{},
+	}
+
+	if composite, err := get_composite_file(node.get_path()); err == nil {
+		export.Composite = composite
+	}
+
+	if levels == 1 {
+		return export
+	}
+
+	for _, child := range node.get_children() {
+		export.Children = append(export.Children, export_tree(child, max(levels-1, 0)))
+	}
+
+	return export
+}
+
+func format_tree(roots []*Node, format string, levels int) (string, error) {
+
+	var exports []*TreeExport
+	for _, root := range roots {
+		exports = append(exports, export_tree(root, levels))
+	}
+
+	var builder strings.Builder
+
+	switch format {
+	case TREE_FORMAT_JSON:
+		if exports == nil {
+			exports = []*TreeExport{}
+		}
+		data, err := json.MarshalIndent(exports, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		builder.Write(data)
+		builder.WriteString("\n")
+
+	case TREE_FORMAT_YAML:
+		if len(exports) < 1 {
+			builder.WriteString("[]\n")
+		}
+		write_tree_yaml(&builder, exports, "")
+
+	case TREE_FORMAT_PATHS:
+		walk_tree_export(exports, func(export *TreeExport) {
+			builder.WriteString(export.Path + "\n")
+		})
+
+	case TREE_FORMAT_DOT:
+		builder.WriteString("digraph cmgr {\n")
+		walk_tree_export(exports, func(export *TreeExport) {
+			fmt.Fprintf(&builder, "  %v [label=%v];\n", strconv.Quote(export.Id), strconv.Quote(export.Group+": "+export.Title))
+			for _, child := range export.Children {
+				fmt.Fprintf(&builder, "  %v -> %v;\n", strconv.Quote(export.Id), strconv.Quote(child.Id))
+			}
+		})
+		builder.WriteString("}\n")
+
+	default:
+		return "", fmt.Errorf("unknown tree format '%v' (expected json, yaml, paths or dot)", format)
+	}
+
+	return builder.String(), nil
+}
+
+func walk_tree_export(exports []*TreeExport, fn func(*TreeExport)) {
+	for _, export := range exports {
+		fn(export)
+		walk_tree_export(export.Children, fn)
+	}
+}
+
+// write_tree_yaml emits exports as a YAML sequence. Strings are written
+// double-quoted, which YAML reads with the same escapes as Go.
+func write_tree_yaml(builder *strings.Builder, exports []*TreeExport, indent string) {
+	for _, export := range exports {
+		fmt.Fprintf(builder, "%v- id: %v\n", indent, strconv.Quote(export.Id))
+		fmt.Fprintf(builder, "%v  group: %v\n", indent, strconv.Quote(export.Group))
+		fmt.Fprintf(builder, "%v  title: %v\n", indent, strconv.Quote(export.Title))
+		fmt.Fprintf(builder, "%v  path: %v\n", indent, strconv.Quote(export.Path))
+		if export.Date != "" {
+			fmt.Fprintf(builder, "%v  date: %v\n", indent, strconv.Quote(export.Date))
+		}
+		if export.Composite != "" {
+			fmt.Fprintf(builder, "%v  composite: %v\n", indent, strconv.Quote(export.Composite))
+		}
+		if len(export.Children) < 1 {
+			fmt.Fprintf(builder, "%v  children: []\n", indent)
+			continue
+		}
+		fmt.Fprintf(builder, "%v  children:\n", indent)
+		write_tree_yaml(builder, export.Children, indent+"    ")
+	}
+}
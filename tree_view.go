@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss/tree"
+)
+
+const (
+	TREE_COLUMN_DATE     = "date"
+	TREE_COLUMN_WORDS    = "words"
+	TREE_COLUMN_MODIFIED = "modified"
+	TREE_COLUMN_BUILD    = "build"
+)
+
+var tree_columns = []string{TREE_COLUMN_DATE, TREE_COLUMN_WORDS, TREE_COLUMN_MODIFIED, TREE_COLUMN_BUILD}
+
+// TreeOptions control what the tree command renders. Nodes are shown if
+// they, or one of their descendants, pass every filter.
+type TreeOptions struct {
+	Levels  int
+	Columns []string
+	Group   string
+	Since   time.Time
+	Tag     string
+	Current map[*Node]bool
+}
+
+// NodeStats holds the metadata shown in tree columns. Word counts and
+// modification times of directories cover all of their lectures.
+type NodeStats struct {
+	Date     string
+	Tags     []string
+	Words    int
+	Modified time.Time
+	Build    *StatusBuild
+}
+
+var node_stats = map[*Node]*NodeStats{}
+
+func get_node_stats(node *Node) *NodeStats {
+
+	if stats, ok := node_stats[node]; ok {
+		return stats
+	}
+
+	stats := &NodeStats{}
+
+	if path, err := node.get_open_path(); err == nil && path != "" {
+		if metadata, err := read_note_metadata(path); err == nil {
+			stats.Date = metadata[CFG_META_DATE]
+			stats.Tags = get_metadata_list(metadata[CFG_META_TAGS])
+		}
+		if info, err := os.Stat(path); err == nil {
+			stats.Modified = info.ModTime()
+		}
+	}
+
+	if filepath.Ext(node.get_path()) == CFG_NOTE_FILETYPE {
+		stats.Words, _ = count_words(node.get_path())
+	} else {
+		stats.Build = get_last_build(node)
+	}
+
+	for _, child := range node.get_children() {
+		child_stats := get_node_stats(child)
+		stats.Words += child_stats.Words
+		if child_stats.Modified.After(stats.Modified) {
+			stats.Modified = child_stats.Modified
+		}
+	}
+
+	node_stats[node] = stats
+
+	return stats
+}
+
+func (o *TreeOptions) filtered() bool {
+	return o.Group != "" || !o.Since.IsZero() || o.Tag != ""
+}
+
+func (o *TreeOptions) matches(node *Node) bool {
+	if o.Group != "" && node.get_group() != o.Group {
+		return false
+	}
+
+	stats := get_node_stats(node)
+
+	if !o.Since.IsZero() && stats.Modified.Before(o.Since) {
+		return false
+	}
+	if o.Tag != "" && !slices.Contains(stats.Tags, o.Tag) {
+		return false
+	}
+
+	return true
+}
+
+// visible reports whether node or one of its descendants passes the filters.
+func (o *TreeOptions) visible(node *Node) bool {
+	if !o.filtered() || o.matches(node) {
+		return true
+	}
+	for _, child := range node.get_children() {
+		if o.visible(child) {
+			return true
+		}
+	}
+	return false
+}
+
+func get_node_label(node *Node, options *TreeOptions) string {
+
+	label := fmt.Sprintf("%v: %v", node.get_group(), node.get_title())
+
+	if options.Current[node] {
+		label = bold_style.Render(label)
+	}
+
+	if len(options.Columns) < 1 {
+		return label
+	}
+
+	stats := get_node_stats(node)
+
+	var columns []string
+
+	for _, column := range options.Columns {
+		switch column {
+		case TREE_COLUMN_DATE:
+			if stats.Date != "" {
+				columns = append(columns, stats.Date)
+			}
+		case TREE_COLUMN_WORDS:
+			columns = append(columns, fmt.Sprintf("%v words", stats.Words))
+		case TREE_COLUMN_MODIFIED:
+			if !stats.Modified.IsZero() {
+				columns = append(columns, stats.Modified.Format(CFG_DATE_FORMAT+" "+CFG_TIME_FORMAT))
+			}
+		case TREE_COLUMN_BUILD:
+			if stats.Build != nil {
+				if stats.Build.Errors > 0 {
+					columns = append(columns, fmt.Sprintf("build: %v error(s)", stats.Build.Errors))
+				} else {
+					columns = append(columns, "build: ok")
+				}
+			}
+		}
+	}
+
+	if len(columns) < 1 {
+		return label
+	}
+
+	return label + "  " + strings.Join(columns, "  ")
+}
+
+// render_branch renders node and levels-1 levels of its descendants that
+// pass the filters; 0 renders the whole branch.
+func render_branch(node *Node, options *TreeOptions, levels int) *tree.Tree {
+
+	t := tree.New().Root(get_node_label(node, options))
+
+	if levels == 1 {
+		return t
+	}
+
+	for _, child := range node.get_children() {
+		if options.visible(child) {
+			t.Child(render_branch(child, options, max(levels-1, 0)))
+		}
+	}
+
+	return t
+}
+
+func get_current_chain() map[*Node]bool {
+	current := map[*Node]bool{}
+	for _, group := range CFG_DEPTH_GROUP {
+		if node := get_current_node(group); node != nil {
+			current[node] = true
+		}
+	}
+	return current
+}
+
+// parse_tree_options reads the tree command's flags.
+func parse_tree_options(flags map[string]string) (*TreeOptions, error) {
+
+	options := &TreeOptions{Current: get_current_chain()}
+
+	if flags["depth"] != "" {
+		depth, err := strconv.Atoi(flags["depth"])
+		if err != nil || depth < 1 {
+			return nil, fmt.Errorf("invalid depth '%v'", flags["depth"])
+		}
+		options.Levels = depth
+	}
+
+	if flags["details"] == "true" {
+		options.Columns = tree_columns
+	}
+	if flags["columns"] != "" {
+		options.Columns = get_metadata_list(flags["columns"])
+		for _, column := range options.Columns {
+			if !slices.Contains(tree_columns, column) {
+				return nil, fmt.Errorf("unknown column '%v' (expected %v)", column, strings.Join(tree_columns, ", "))
+			}
+		}
+	}
+
+	if flags["group"] != "" {
+		options.Group = get_alias_group(flags["group"])
+		if !valid_node_group(options.Group) {
+			return nil, fmt.Errorf("invalid group: %v", flags["group"])
+		}
+	}
+
+	if flags["modified-since"] != "" {
+		since, err := parse_since(flags["modified-since"])
+		if err != nil {
+			return nil, err
+		}
+		options.Since = since
+	}
+
+	options.Tag = flags["tag"]
+
+	return options, nil
+}
+
+// parse_since accepts a date, or an age such as 7d, 2w or 12h.
+func parse_since(value string) (time.Time, error) {
+
+	if date, err := time.ParseInLocation(CFG_DATE_FORMAT, value, time.Local); err == nil {
+		return date, nil
+	}
+
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+
+	for suffix, unit := range units {
+		if number, found := strings.CutSuffix(value, suffix); found {
+			if count, err := strconv.Atoi(number); err == nil && count >= 0 {
+				return time.Now().Add(-time.Duration(count) * unit), nil
+			}
+		}
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time '%v' (expected e.g. 7d, 2w, 12h or %v)", value, CFG_DATE_FORMAT)
+}
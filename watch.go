@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watch monitors every directory below node and rebuilds the affected
+// documents once changes have settled for CFG_WATCH_DEBOUNCE.
+func watch(node *Node) error {
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := add_watch_directories(watcher, node.get_path()); err != nil {
+		return err
+	}
+
+	fmt.Printf("Watching %v '%v' for changes. Press Ctrl+C to stop.\n", node.get_group(), node.get_title())
+
+	changed := map[string]bool{}
+	timer := time.NewTimer(CFG_WATCH_DEBOUNCE)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					add_watch_directories(watcher, event.Name)
+					continue
+				}
+			}
+
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+
+			if !slices.Contains(CFG_WATCH_FILETYPES, filepath.Ext(event.Name)) {
+				continue
+			}
+
+			changed[event.Name] = true
+			timer.Reset(CFG_WATCH_DEBOUNCE)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Println(err)
+
+		case <-timer.C:
+			rebuild_changed(changed)
+			changed = map[string]bool{}
+		}
+	}
+}
+
+func add_watch_directories(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			return nil
+		}
+
+		if entry.Name() == CFG_BUILD_DIR || (path != root && strings.HasPrefix(entry.Name(), ".")) {
+			return filepath.SkipDir
+		}
+
+		return watcher.Add(path)
+	})
+}
+
+func rebuild_changed(changed map[string]bool) {
+
+	targets := map[string]*Node{}
+
+	for path := range changed {
+		node := find_node_by_path(path)
+		if node == nil {
+			continue
+		}
+
+		target, composite_file, err := get_build_target(node)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		targets[composite_file] = target
+	}
+
+	for _, target := range targets {
+		result, err := build_node(target)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		fmt.Println(result)
+	}
+}